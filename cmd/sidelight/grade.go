@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/schollz/progressbar/v3"
@@ -13,13 +17,27 @@ import (
 	"sidelight/internal/ai"
 	"sidelight/internal/app"
 	"sidelight/internal/extractor"
+	"sidelight/internal/preview"
+	"sidelight/internal/style"
+	"sidelight/internal/xmp"
+	"sidelight/pkg/models"
 )
 
 var (
 	apiKey      string
 	concurrency int
-	style       string
+	gradeStyle  string
 	userPrompt  string
+	refine      string
+	references  []string
+	samples     int
+	budget      float64
+	series      bool
+	seriesSize  int
+	describe    bool
+	crop        bool
+	onInvalid   string
+	safety      string
 	formats     []string
 )
 
@@ -33,13 +51,30 @@ var gradeCmd = &cobra.Command{
 
 func init() {
 	// Flags specific to the 'grade' command
-	gradeCmd.Flags().StringVar(&apiKey, "api-key", "", "Gemini API Key (or set SL_GEMINI_API_KEY env var)")
-	gradeCmd.Flags().String("endpoint", "", "Gemini Endpoint URL")
-	gradeCmd.Flags().String("model", "", "Gemini Model Name")
+	gradeCmd.Flags().String("provider", "", "AI provider (gemini, openai, ollama, auto)")
+	gradeCmd.Flags().StringVar(&apiKey, "api-key", "", "API Key for the selected provider (or set SL_GEMINI_API_KEY / SL_OPENAI_API_KEY env var)")
+	gradeCmd.Flags().String("endpoint", "", "Provider Endpoint URL")
+	gradeCmd.Flags().String("model", "", "Provider Model Name")
 	gradeCmd.Flags().IntVarP(&concurrency, "concurrency", "j", 4, "Number of concurrent files to process")
-	gradeCmd.Flags().StringVarP(&style, "style", "s", "natural", "Grading style (natural, cinematic, film, bw, portrait)")
+	gradeCmd.Flags().Int("max-retries", 3, "Retries per AI call on transient failures (rate limits, overload)")
+	gradeCmd.Flags().Int("rpm", 0, "Maximum AI requests per minute across all workers (0 = unlimited)")
+	gradeCmd.Flags().Bool("no-cache", false, "Do not read or write the AI response cache")
+	gradeCmd.Flags().Bool("refresh", false, "Ignore cached AI responses and overwrite them with fresh ones")
+	gradeCmd.Flags().StringVarP(&gradeStyle, "style", "s", "natural", "Grading style (see `sidelight styles list`), or auto to pick one per photo")
 	gradeCmd.Flags().StringVarP(&userPrompt, "prompt", "p", "", "Custom instructions (e.g., 'warmer', 'high contrast')")
+	gradeCmd.Flags().StringVar(&refine, "refine", "", "Adjust the existing .xmp/.pp3 next to each file with this feedback (e.g., 'less contrast')")
+	gradeCmd.Flags().StringSliceVar(&references, "reference", nil, "Reference image(s) whose tone and palette the grade should match (repeatable)")
+	gradeCmd.Flags().IntVar(&samples, "samples", 1, "Number of independent AI analyses per photo, combined by per-parameter median")
+	gradeCmd.Flags().Float64Var(&budget, "budget", 0, "Stop starting new files once the estimated AI cost reaches this many USD (0 = unlimited)")
+	gradeCmd.Flags().BoolVar(&series, "series", false, "Grade the batch as one series: derive a shared base look from a contact sheet, then grade each photo relative to it")
+	gradeCmd.Flags().IntVar(&seriesSize, "series-size", 24, "Photos per contact sheet in --series mode; larger batches are split into groups in file order")
+	gradeCmd.Flags().BoolVar(&describe, "describe", false, "Ask the AI for a title, caption and keywords and write them into the sidecar (dc:title, dc:description, dc:subject)")
+	gradeCmd.Flags().BoolVar(&crop, "crop", false, "Ask the AI to straighten the horizon and suggest a crop, written as crs:Crop* in XMP and [Crop]/[Rotation] in PP3")
+	gradeCmd.Flags().StringVar(&onInvalid, "on-invalid", string(xmp.PolicyClamp), "What to do with out-of-range LR parameters: clamp them, or reject the file without writing an XMP")
+	gradeCmd.Flags().StringVar(&safety, "safety", xmp.DefaultSafetyProfile, "Safety profile limiting how far LR parameters may stray from neutral (off, standard, strict)")
 	gradeCmd.Flags().StringSliceVarP(&formats, "format", "f", []string{"xmp"}, "Output formats (xmp, pp3, rt, all)")
+	gradeCmd.Flags().Int("preview-size", preview.DefaultOptions().LongEdge, "Long edge in pixels of the preview sent to the AI (0 = original size)")
+	gradeCmd.Flags().Int("preview-quality", preview.DefaultOptions().Quality, "JPEG quality (1-100) of the preview sent to the AI")
 
 	// Env vars - 设置环境变量作为最低优先级的默认值
 	viper.SetEnvPrefix("GEMINI")
@@ -55,19 +90,61 @@ type GradeParams struct {
 	Extractor    extractor.Extractor
 	Concurrency  int
 	Style        string
+	StyleDef     *style.Style    // resolved definition of Style, nil uses the built-in one
+	Styles       *style.Registry // catalog for --style auto, nil uses the built-in styles
 	UserPrompt   string
+	Refine       string   // feedback for refining the existing sidecars, empty grades from scratch
+	References   []string // reference images whose look should be matched
+	Samples      int      // analyses per photo combined into one result, <2 means one
 	Formats      []string
+	Preview      preview.Options      // zero value keeps the processor defaults
+	Model        string               // model the prices are looked up for, see ai.Config.Model
+	Prices       ai.PriceTable        // prices for the cost estimate, nil uses ai.DefaultPrices
+	Budget       float64              // USD; once spent, remaining files are skipped. 0 = unlimited
+	Series       bool                 // grade relative to a base look shared by each group of files
+	SeriesSize   int                  // photos per series group, <= 0 puts all files in one group
+	Describe     bool                 // write an AI scene description (title, caption, keywords) into the sidecars
+	Crop         bool                 // write an AI crop and straightening suggestion into the sidecars
+	Sanitize     *xmp.SanitizeOptions // LR range checks, nil uses the processor defaults
 	ShowProgress bool
 }
 
+// errBudgetExceeded 表示文件因超出 --budget 而未被处理
+var errBudgetExceeded = errors.New("budget exceeded")
+
 // processGrading 执行实际的图片处理逻辑
 func processGrading(ctx context.Context, params GradeParams) []error {
 	processor := app.NewProcessor(params.Extractor, params.AIClient)
 	processor.Formats = params.Formats
+	if params.Styles != nil {
+		processor.Styles = params.Styles
+	}
+	if params.Sanitize != nil {
+		processor.Sanitize = *params.Sanitize
+	}
+	if params.Preview != (preview.Options{}) {
+		processor.Preview = params.Preview
+	}
 
 	opts := ai.AnalysisOptions{
 		Style:      params.Style,
 		UserPrompt: params.UserPrompt,
+		Definition: params.StyleDef,
+		Samples:    params.Samples,
+		Describe:   params.Describe,
+		Crop:       params.Crop,
+	}
+	if params.Refine != "" {
+		opts.Refine = &ai.Refinement{Feedback: params.Refine}
+	}
+
+	// 参考图只需提取一次，所有文件共用
+	for _, ref := range params.References {
+		data, err := processor.LoadReference(ctx, ref)
+		if err != nil {
+			return []error{err}
+		}
+		opts.ReferenceImages = append(opts.ReferenceImages, data)
 	}
 
 	files := params.Files
@@ -75,20 +152,67 @@ func processGrading(ctx context.Context, params GradeParams) []error {
 		return []error{fmt.Errorf("no files to process")}
 	}
 
+	prices := params.Prices
+	if prices == nil {
+		prices = ai.DefaultPrices()
+	}
+	// 没有价格就无法估算费用，--budget 将永远不会生效
+	if _, ok := prices.Lookup(params.Model); params.Budget > 0 && !ok {
+		return []error{fmt.Errorf("--budget needs a price for model %q: add it under prices in the config file", params.Model)}
+	}
+	// 整个批次的用量，包括失败的文件
+	ctx, usage := ai.TrackUsage(ctx)
+	overBudget := func() bool {
+		cost, ok := prices.Cost(usage.Total())
+		return params.Budget > 0 && ok && cost >= params.Budget
+	}
+
+	fileOpts := make(map[string]ai.AnalysisOptions, len(files))
+	for _, file := range files {
+		fileOpts[file] = opts
+	}
+	// 系列模式：每组先分析缩略图拼版得到统一的基础风格，再逐张在其基础上微调
+	if params.Series {
+		groups := seriesGroups(files, params.SeriesSize)
+		for i, group := range groups {
+			if params.ShowProgress {
+				fmt.Printf("Analyzing series %d/%d (%d photos)...\n", i+1, len(groups), len(group))
+			}
+			base, err := processor.AnalyzeSeries(ctx, group, opts)
+			if err != nil {
+				return []error{err}
+			}
+			for _, file := range group {
+				o := opts
+				o.Series = base
+				fileOpts[file] = o
+			}
+		}
+	}
+
 	var bar *progressbar.ProgressBar
 	if params.ShowProgress {
 		bar = progressbar.Default(int64(len(files)))
 	}
 
+	type outcome struct {
+		result *models.ProcessingResult
+		err    error
+	}
 	jobs := make(chan string, len(files))
-	results := make(chan error, len(files))
+	results := make(chan outcome, len(files))
 
 	// Start workers
 	for w := 1; w <= params.Concurrency; w++ {
 		go func() {
 			for file := range jobs {
-				_, err := processor.ProcessFile(ctx, file, opts)
-				results <- err
+				// 已处理中的文件会完成，因此实际花费可能略超预算
+				if overBudget() {
+					results <- outcome{err: fmt.Errorf("%s: skipped: %w", filepath.Base(file), errBudgetExceeded)}
+					continue
+				}
+				res, err := processor.ProcessFile(ctx, file, fileOpts[file])
+				results <- outcome{res, err}
 			}
 		}()
 	}
@@ -101,55 +225,222 @@ func processGrading(ctx context.Context, params GradeParams) []error {
 
 	// Collect results
 	var errorsList []error
+	var unstable, picked, sanitized []string
 	for i := 0; i < len(files); i++ {
-		err := <-results
-		if err != nil {
-			errorsList = append(errorsList, err)
+		out := <-results
+		if out.err != nil {
+			errorsList = append(errorsList, out.err)
+		} else {
+			unstable = append(unstable, unstableParams(out.result)...)
+			for _, s := range out.result.Sanitized {
+				sanitized = append(sanitized, fmt.Sprintf("%s: %s", filepath.Base(out.result.SourcePath), s))
+			}
+			if choice := out.result.AutoStyle; choice != nil {
+				picked = append(picked, fmt.Sprintf("%s: %s (%s)", filepath.Base(out.result.SourcePath), out.result.Style, choice.Scene))
+			}
 		}
 		if bar != nil {
 			bar.Add(1)
 		}
 	}
 
+	if total := usage.Total(); total.Calls > 0 {
+		fmt.Printf("\nAI usage: %s\n", formatUsage(total, prices))
+	}
+
+	// --style auto 为每张照片选择的风格
+	if len(picked) > 0 {
+		sort.Strings(picked)
+		fmt.Println("\nStyles picked:")
+		for _, p := range picked {
+			fmt.Printf("- %s\n", p)
+		}
+	}
+
+	// 超出范围并被修正的 LR 参数
+	if len(sanitized) > 0 {
+		sort.Strings(sanitized)
+		fmt.Println("\nOut-of-range parameters adjusted:")
+		for _, s := range sanitized {
+			fmt.Printf("- %s\n", s)
+		}
+	}
+
+	// 多次采样结果分歧较大的参数，提示用户复查
+	if len(unstable) > 0 {
+		fmt.Printf("\nUnstable parameters across %d samples:\n", params.Samples)
+		for _, u := range unstable {
+			fmt.Printf("- %s\n", u)
+		}
+	}
+
 	return errorsList
 }
 
-func runGrade(cmd *cobra.Command, args []string) {
-	key := viper.GetString("gemini_api_key")
-	if cmd.Flags().Changed("api-key") {
-		flagValue, _ := cmd.Flags().GetString("api-key")
-		if flagValue != "" {
-			key = flagValue
+// errorKinds 是批次汇总中错误分组的顺序和标题
+var errorKinds = []struct {
+	title string
+	err   error
+}{
+	{"Blocked by safety filters", ai.ErrSafetyBlocked},
+	{"Quota or rate limit exceeded", ai.ErrQuota},
+	{"Authentication failed", ai.ErrAuth},
+	{"Malformed AI response", ai.ErrMalformedResponse},
+	{"Empty AI response", ai.ErrEmptyResponse},
+	{"Rejected, parameters out of range", xmp.ErrOutOfRange},
+	{"Skipped, budget exceeded", errBudgetExceeded},
+}
+
+// errorGroup 是同一类型的失败
+type errorGroup struct {
+	Title  string
+	Errors []error
+}
+
+// groupErrors 按失败类型对错误分组，无法归类的放在最后的 "Other" 组，空组省略
+func groupErrors(errs []error) []errorGroup {
+	groups := make([]errorGroup, len(errorKinds)+1)
+	for i, kind := range errorKinds {
+		groups[i].Title = kind.title
+	}
+	groups[len(errorKinds)].Title = "Other"
+
+	for _, err := range errs {
+		i := len(errorKinds)
+		for k, kind := range errorKinds {
+			if errors.Is(err, kind.err) {
+				i = k
+				break
+			}
 		}
+		groups[i].Errors = append(groups[i].Errors, err)
 	}
 
-	endpoint := viper.GetString("gemini_endpoint_url")
-	if cmd.Flags().Changed("endpoint") {
-		flagValue, _ := cmd.Flags().GetString("endpoint")
-		if flagValue != "" {
-			endpoint = flagValue
+	var nonEmpty []errorGroup
+	for _, g := range groups {
+		if len(g.Errors) > 0 {
+			nonEmpty = append(nonEmpty, g)
 		}
 	}
+	return nonEmpty
+}
+
+// seriesGroups 将文件按顺序均分为不超过 size 张的组，size <= 0 时整批为一组
+func seriesGroups(files []string, size int) [][]string {
+	if size <= 0 || len(files) <= size {
+		return [][]string{files}
+	}
+	n := (len(files) + size - 1) / size
+	groups := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		groups = append(groups, files[i*len(files)/n:(i+1)*len(files)/n])
+	}
+	return groups
+}
+
+// formatUsage 汇总 token 用量和估算费用
+func formatUsage(u models.Usage, prices ai.PriceTable) string {
+	line := fmt.Sprintf("%d calls, %d prompt + %d image + %d output tokens",
+		u.Calls, u.PromptTokens, u.ImageTokens, u.OutputTokens)
+	if cost, ok := prices.Cost(u); ok {
+		return line + fmt.Sprintf(", estimated cost $%.4f", cost)
+	}
+	return line + fmt.Sprintf(", no price configured for model %q", u.Model)
+}
 
-	modelName := viper.GetString("gemini_model_name")
-	if cmd.Flags().Changed("model") {
-		flagValue, _ := cmd.Flags().GetString("model")
-		if flagValue != "" {
-			modelName = flagValue
+// unstableParams 列出一个文件中多次采样分歧过大的参数
+func unstableParams(res *models.ProcessingResult) []string {
+	var lines []string
+	for _, s := range res.Spread {
+		if s.Unstable {
+			lines = append(lines, fmt.Sprintf("%s [%s] %s: %g..%g, used %g",
+				filepath.Base(res.SourcePath), s.Format, s.Field, s.Min, s.Max, s.Median))
 		}
 	}
+	return lines
+}
 
-	if key == "" {
-		log.Fatal("API Key is required. Provide it via config file (highest priority), --api-key flag, or SL_GEMINI_API_KEY environment variable.")
+// priceTable 返回费用估算使用的价格表：内置价格加上配置文件中的 prices (模型名 -> input/output，单位 USD / 百万 token)。
+// 本地运行的 ollama 和 auto 不产生费用。
+func priceTable(cfg ai.Config) ai.PriceTable {
+	if cfg.Provider == ai.ProviderOllama || cfg.Provider == ai.ProviderAuto {
+		return ai.PriceTable{"": {}} // 空前缀匹配所有模型
+	}
+	prices := ai.DefaultPrices()
+	var custom ai.PriceTable
+	if err := viper.UnmarshalKey("prices", &custom); err != nil {
+		log.Printf("Ignoring invalid prices in config: %v", err)
+	}
+	for model, price := range custom {
+		prices[model] = price
+	}
+	return prices
+}
+
+// sanitizeOptions 根据 --on-invalid 和 --safety 确定 LR 参数的范围检查方式
+func sanitizeOptions() (xmp.SanitizeOptions, error) {
+	policy := xmp.Policy(strings.ToLower(onInvalid))
+	if policy != xmp.PolicyClamp && policy != xmp.PolicyReject {
+		return xmp.SanitizeOptions{}, fmt.Errorf("invalid --on-invalid %q (use clamp or reject)", onInvalid)
+	}
+	profile, ok := xmp.SafetyProfiles[strings.ToLower(safety)]
+	if !ok {
+		return xmp.SanitizeOptions{}, fmt.Errorf("unknown safety profile %q (available: %s)", safety, strings.Join(xmp.SafetyProfileNames(), ", "))
+	}
+	return xmp.SanitizeOptions{Policy: policy, Profile: profile}, nil
+}
+
+// previewOptions 根据配置文件 (preview_size, preview_quality) 和命令行参数确定预览图的缩放与压缩设置。
+func previewOptions(cmd *cobra.Command) preview.Options {
+	opts := preview.DefaultOptions()
+	if viper.IsSet("preview_size") {
+		opts.LongEdge = viper.GetInt("preview_size")
+	}
+	if viper.IsSet("preview_quality") {
+		opts.Quality = viper.GetInt("preview_quality")
+	}
+	if cmd.Flags().Changed("preview-size") {
+		opts.LongEdge, _ = cmd.Flags().GetInt("preview-size")
+	}
+	if cmd.Flags().Changed("preview-quality") {
+		opts.Quality, _ = cmd.Flags().GetInt("preview-quality")
+	}
+	return opts
+}
+
+func runGrade(cmd *cobra.Command, args []string) {
+	cfg := resolveAIConfig(cmd)
+
+	if cfg.APIKey == "" && cfg.RequiresAPIKey() {
+		log.Fatalf("API Key is required for provider %q. Provide it via config file (highest priority), --api-key flag, or SL_%s_API_KEY environment variable.", cfg.Provider, strings.ToUpper(cfg.Provider))
+	}
+
+	// auto 在处理每张照片时再从风格库中选择
+	styles := mustLoadStyles()
+	var styleDef *style.Style
+	styleName := style.Auto
+	if !strings.EqualFold(gradeStyle, style.Auto) {
+		var err error
+		if styleDef, err = styles.Get(gradeStyle); err != nil {
+			log.Fatal(err)
+		}
+		styleName = styleDef.Name
+	}
+
+	sanitize, err := sanitizeOptions()
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	ctx := context.Background()
 	ext := extractor.NewExifToolExtractor()
-	aiClient, err := ai.NewGeminiClient(ctx, key, endpoint, modelName)
+	aiClient, err := newAIClient(ctx, cmd, cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize AI client: %v", err)
 	}
-	defer aiClient.Close()
+	if closer, ok := aiClient.(io.Closer); ok {
+		defer closer.Close()
+	}
 
 	files := collectFiles(args)
 	if len(files) == 0 {
@@ -170,9 +461,23 @@ func runGrade(cmd *cobra.Command, args []string) {
 		AIClient:     aiClient,
 		Extractor:    ext,
 		Concurrency:  concurrency,
-		Style:        style,
+		Style:        styleName,
+		StyleDef:     styleDef,
+		Styles:       styles,
 		UserPrompt:   userPrompt,
+		Refine:       refine,
+		References:   references,
+		Samples:      samples,
 		Formats:      finalFormats,
+		Preview:      previewOptions(cmd),
+		Model:        cfg.Model(),
+		Prices:       priceTable(cfg),
+		Budget:       budget,
+		Series:       series,
+		SeriesSize:   seriesSize,
+		Describe:     describe,
+		Crop:         crop,
+		Sanitize:     &sanitize,
 		ShowProgress: true,
 	}
 
@@ -181,8 +486,11 @@ func runGrade(cmd *cobra.Command, args []string) {
 	fmt.Printf("\nFinished grading %d files.\n", len(files))
 	if len(allErrs) > 0 {
 		fmt.Printf("Encountered %d errors:\n", len(allErrs))
-		for _, e := range allErrs {
-			fmt.Printf("- %v\n", e)
+		for _, g := range groupErrors(allErrs) {
+			fmt.Printf("\n%s (%d):\n", g.Title, len(g.Errors))
+			for _, e := range g.Errors {
+				fmt.Printf("- %v\n", e)
+			}
 		}
 	}
 }
@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"sidelight/internal/ai"
@@ -114,3 +118,81 @@ func TestProcessGradingEmptyFiles(t *testing.T) {
 		t.Errorf("Expected 'no files to process' error, got: %v", errs[0])
 	}
 }
+
+// TestProcessGradingBudgetWithoutPrice 验证 --budget 在模型没有价格时于处理前报错
+func TestProcessGradingBudgetWithoutPrice(t *testing.T) {
+	params := GradeParams{
+		Files:       []string{"a.ARW"},
+		Concurrency: 1,
+		Model:       "my-finetune",
+		Budget:      1,
+	}
+
+	errs := processGrading(context.Background(), params)
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "prices") {
+		t.Fatalf("Expected one error asking for a prices entry, got %v", errs)
+	}
+}
+
+// TestProcessGradingBudgetOllama 验证本地 ollama 模型免费，--budget 不会拒绝处理
+func TestProcessGradingBudgetOllama(t *testing.T) {
+	params := GradeParams{
+		Files:       []string{filepath.Join(t.TempDir(), "a.ARW")},
+		Extractor:   extractor.NewExifToolExtractor(),
+		Concurrency: 1,
+		Model:       ai.Config{Provider: ai.ProviderOllama}.Model(),
+		Prices:      priceTable(ai.Config{Provider: ai.ProviderOllama}),
+		Budget:      1,
+	}
+
+	for _, err := range processGrading(context.Background(), params) {
+		if strings.Contains(err.Error(), "budget") {
+			t.Errorf("A local model should pass the budget check, got %v", err)
+		}
+	}
+}
+
+// TestSeriesGroups 验证系列分组均匀且保持文件顺序
+func TestSeriesGroups(t *testing.T) {
+	files := []string{"1", "2", "3", "4", "5", "6", "7"}
+
+	groups := seriesGroups(files, 3)
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(groups))
+	}
+	var joined []string
+	for _, g := range groups {
+		if len(g) < 2 || len(g) > 3 {
+			t.Errorf("Expected groups of 2-3 files, got %v", g)
+		}
+		joined = append(joined, g...)
+	}
+	if fmt.Sprint(joined) != fmt.Sprint(files) {
+		t.Errorf("Groups must keep file order, got %v", joined)
+	}
+
+	if groups := seriesGroups(files, 0); len(groups) != 1 {
+		t.Errorf("Expected a single group without a size limit, got %d", len(groups))
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	errs := []error{
+		fmt.Errorf("a.ARW: %w", ai.ErrQuota),
+		errors.New("b.ARW: disk full"),
+		fmt.Errorf("c.ARW: giving up after 3 attempts: %w", &ai.StatusError{Provider: "openai", StatusCode: 429}),
+		fmt.Errorf("d.ARW: skipped: %w", errBudgetExceeded),
+	}
+
+	groups := groupErrors(errs)
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %+v", groups)
+	}
+	if groups[0].Title != "Quota or rate limit exceeded" || len(groups[0].Errors) != 2 {
+		t.Errorf("Expected both quota errors in the first group, got %+v", groups[0])
+	}
+	if last := groups[len(groups)-1]; last.Title != "Other" || len(last.Errors) != 1 {
+		t.Errorf("Unclassified errors should come last, got %+v", last)
+	}
+}
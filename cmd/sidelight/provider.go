@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"sidelight/internal/ai"
+)
+
+func init() {
+	viper.BindEnv("ai_provider", "SL_AI_PROVIDER")
+	viper.BindEnv("openai_api_key", "SL_OPENAI_API_KEY")
+	viper.BindEnv("openai_endpoint_url", "SL_OPENAI_ENDPOINT_URL")
+	viper.BindEnv("openai_model_name", "SL_OPENAI_MODEL_NAME")
+	viper.BindEnv("ollama_endpoint_url", "SL_OLLAMA_ENDPOINT_URL")
+	viper.BindEnv("ollama_model_name", "SL_OLLAMA_MODEL_NAME")
+}
+
+// resolveAIConfig 根据配置文件、环境变量和命令行参数确定 AI 后端配置。
+// 每个 provider 使用独立的配置键: <provider>_api_key, <provider>_endpoint_url, <provider>_model_name
+func resolveAIConfig(cmd *cobra.Command) ai.Config {
+	provider := viper.GetString("ai_provider")
+	if v := flagString(cmd, "provider"); v != "" {
+		provider = v
+	}
+	provider = strings.ToLower(provider)
+	if provider == "" {
+		provider = ai.ProviderGemini
+	}
+
+	cfg := ai.Config{
+		Provider:  provider,
+		APIKey:    viper.GetString(provider + "_api_key"),
+		Endpoint:  viper.GetString(provider + "_endpoint_url"),
+		ModelName: viper.GetString(provider + "_model_name"),
+	}
+	if v := flagString(cmd, "api-key"); v != "" {
+		cfg.APIKey = v
+	}
+	if v := flagString(cmd, "endpoint"); v != "" {
+		cfg.Endpoint = v
+	}
+	if v := flagString(cmd, "model"); v != "" {
+		cfg.ModelName = v
+	}
+	return cfg
+}
+
+// flagString 返回显式设置的命令行参数值，未设置或不存在时返回空字符串。
+func flagString(cmd *cobra.Command, name string) string {
+	if cmd == nil || !cmd.Flags().Changed(name) {
+		return ""
+	}
+	v, _ := cmd.Flags().GetString(name)
+	return v
+}
+
+// newAIClient 创建 AI 客户端，并包装统一的重试、退避和限流层以及磁盘缓存。
+// 同一个客户端实例在所有 worker 之间共享，因此 --rpm 是全局限制。
+func newAIClient(ctx context.Context, cmd *cobra.Command, cfg ai.Config) (ai.Client, error) {
+	client, err := ai.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// auto 在本地确定性计算，无需重试、限流或缓存
+	if cfg.Provider == ai.ProviderAuto {
+		return client, nil
+	}
+
+	retryCfg := ai.DefaultRetryConfig()
+	if viper.IsSet("max_retries") {
+		retryCfg.MaxAttempts = viper.GetInt("max_retries") + 1
+	}
+	retryCfg.RequestsPerMinute = viper.GetInt("requests_per_minute")
+	if cmd != nil && cmd.Flags().Changed("max-retries") {
+		retries, _ := cmd.Flags().GetInt("max-retries")
+		retryCfg.MaxAttempts = retries + 1
+	}
+	if cmd != nil && cmd.Flags().Changed("rpm") {
+		retryCfg.RequestsPerMinute, _ = cmd.Flags().GetInt("rpm")
+	}
+
+	client = ai.NewRetryClient(client, retryCfg)
+
+	// 缓存放在重试层之外：命中缓存时既不消耗配额也不占用限流
+	noCache := viper.GetBool("no_cache")
+	if cmd != nil && cmd.Flags().Changed("no-cache") {
+		noCache, _ = cmd.Flags().GetBool("no-cache")
+	}
+	if noCache {
+		return client, nil
+	}
+
+	cacheDir := viper.GetString("cache_dir")
+	if cacheDir == "" {
+		if cacheDir, err = ai.DefaultCacheDir(); err != nil {
+			return client, nil
+		}
+	}
+	refresh := false
+	if cmd != nil && cmd.Flags().Changed("refresh") {
+		refresh, _ = cmd.Flags().GetBool("refresh")
+	}
+	return ai.NewCacheClient(client, cacheDir, cfg.Provider+"/"+cfg.Model(), refresh), nil
+}
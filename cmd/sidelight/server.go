@@ -2,12 +2,11 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
-	"sidelight/internal/ai"
 	"sidelight/internal/app"
 	"sidelight/internal/extractor"
 	"sidelight/internal/server"
@@ -27,31 +26,32 @@ var serverCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.Flags().IntVarP(&serverPort, "port", "p", 8080, "Port to listen on")
+	serverCmd.Flags().String("provider", "", "AI provider (gemini, openai, ollama, auto)")
 }
 
 func runServer(cmd *cobra.Command, args []string) {
-	key := viper.GetString("gemini_api_key")
-	if key == "" {
-		log.Fatal("Error: GEMINI_API_KEY is not set. Please set it via environment variable or config file.")
+	cfg := resolveAIConfig(cmd)
+	if cfg.APIKey == "" && cfg.RequiresAPIKey() {
+		log.Fatalf("Error: API key for provider %q is not set. Please set it via environment variable or config file.", cfg.Provider)
 	}
 
-	endpoint := viper.GetString("gemini_endpoint_url")
-	modelName := viper.GetString("gemini_model_name")
-
 	ctx := context.Background()
 
 	// Initialize dependencies
-	aiClient, err := ai.NewGeminiClient(ctx, key, endpoint, modelName)
+	aiClient, err := newAIClient(ctx, cmd, cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize AI client: %v", err)
 	}
-	defer aiClient.Close()
+	if closer, ok := aiClient.(io.Closer); ok {
+		defer closer.Close()
+	}
 
 	ext := extractor.NewExifToolExtractor()
 	processor := app.NewProcessor(ext, aiClient)
 
 	// Start server
 	srv := server.NewServer(processor, serverPort)
+	srv.Styles = mustLoadStyles()
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
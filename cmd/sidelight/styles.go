@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+
+	"sidelight/internal/style"
+)
+
+var stylesCmd = &cobra.Command{
+	Use:   "styles",
+	Short: "List and inspect grading styles",
+	Long: `Grading styles are built in and can be extended or overridden with JSON/YAML
+files in ~/.config/sidelight/styles (or the directory set by "styles_dir" in the config).`,
+}
+
+var stylesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available grading styles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry := mustLoadStyles()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSOURCE\tDESCRIPTION")
+		for _, s := range registry.List() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Source, s.Description)
+		}
+		w.Flush()
+	},
+}
+
+var stylesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show the full definition of a grading style",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := mustLoadStyles().Get(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("# source: %s\n", s.Source)
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(s); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stylesCmd)
+	stylesCmd.AddCommand(stylesListCmd, stylesShowCmd)
+}
+
+// loadStyles 加载内置风格以及用户风格目录 (~/.config/sidelight/styles 或配置项 styles_dir) 中的定义。
+func loadStyles() (*style.Registry, error) {
+	var dirs []string
+	if dir, err := style.DefaultDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	if dir := viper.GetString("styles_dir"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return style.Load(dirs...)
+}
+
+func mustLoadStyles() *style.Registry {
+	registry, err := loadStyles()
+	if err != nil {
+		log.Fatalf("Failed to load styles: %v", err)
+	}
+	return registry
+}
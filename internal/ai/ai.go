@@ -2,6 +2,10 @@ package ai
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
+	"sidelight/internal/style"
 	"sidelight/pkg/models"
 )
 
@@ -16,4 +20,139 @@ type Client interface {
 type AnalysisOptions struct {
 	Style      string
 	UserPrompt string
+
+	// Definition is the resolved Style, including user-defined styles. When
+	// nil, Style is looked up among the built-in styles.
+	Definition *style.Style `json:",omitempty"`
+
+	// Refine, when set, asks for an adjustment of a previous grade instead of
+	// grading from scratch.
+	Refine *Refinement `json:",omitempty"`
+
+	// ReferenceImages are JPEG images whose tone and palette the result should
+	// match. They are sent after the photo being graded.
+	ReferenceImages [][]byte `json:"-"`
+
+	// Samples is the number of independent analyses Consensus combines;
+	// values below 2 mean a single call.
+	Samples int `json:"-"`
+
+	// Sample identifies one of several analyses of the same input, so each
+	// is cached separately.
+	Sample int `json:",omitempty"`
+
+	// SeriesSheet is the number of photos shown when the image is a contact
+	// sheet of a series. The result is then the shared base look of the series.
+	SeriesSheet int `json:",omitempty"`
+
+	// Series, when set, is the base look of the series the photo belongs to;
+	// the photo is graded relative to it so the set stays consistent.
+	Series *SeriesBase `json:",omitempty"`
+
+	// Describe asks for a scene description (title, caption, scene type and
+	// keywords) in the Scene field of the result.
+	Describe bool `json:",omitempty"`
+
+	// Crop asks for a suggested crop and horizon correction in the Crop
+	// field of the result.
+	Crop bool `json:",omitempty"`
+}
+
+// SeriesBase is the shared look of a series, from analyzing its contact sheet.
+type SeriesBase struct {
+	LR    *models.GradingParams `json:",omitempty"`
+	PP3   *models.PP3Params     `json:",omitempty"`
+	Style *models.StyleChoice   `json:",omitempty"` // style picked for the series with style.Auto
+}
+
+// images returns the photo followed by the reference images, in upload order.
+func (o AnalysisOptions) images(imageData []byte) [][]byte {
+	return append([][]byte{imageData}, o.ReferenceImages...)
+}
+
+// Refinement carries a previous grade and the feedback to apply to it. The
+// model returns the complete updated parameter set.
+type Refinement struct {
+	Feedback string
+	LR       *models.GradingParams `json:",omitempty"` // starting point for AnalyzeImageLR
+	PP3      *models.PP3Params     `json:",omitempty"` // starting point for AnalyzeImageForPP3
+}
+
+var builtinStyles = style.Builtin()
+
+// StyleDefinition returns the style to grade with: Definition if set,
+// otherwise the built-in style named by Style, falling back to the default.
+func (o AnalysisOptions) StyleDefinition() *style.Style {
+	if o.Definition != nil {
+		return o.Definition
+	}
+	if s, err := builtinStyles.Get(o.Style); err == nil {
+		return s
+	}
+	s, _ := builtinStyles.Get(style.Default)
+	return s
+}
+
+// Supported AI providers.
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderAuto   = "auto" // deterministic, model-free baseline
+)
+
+// Config selects and configures an AI backend.
+type Config struct {
+	Provider  string // One of the Provider* constants, defaults to Gemini
+	APIKey    string
+	Endpoint  string
+	ModelName string
+}
+
+// RequiresAPIKey reports whether the provider cannot work without an API key.
+// Local servers (Ollama, or llama.cpp behind the OpenAI provider with a custom
+// endpoint) and the auto grader run without one.
+func (c Config) RequiresAPIKey() bool {
+	switch strings.ToLower(c.Provider) {
+	case "", ProviderGemini:
+		return true
+	case ProviderOpenAI:
+		return c.Endpoint == ""
+	default:
+		return false
+	}
+}
+
+// Model returns the model the provider will use: ModelName, or the
+// provider's default when it is empty. The auto grader has no model.
+func (c Config) Model() string {
+	if c.ModelName != "" {
+		return c.ModelName
+	}
+	switch strings.ToLower(c.Provider) {
+	case "", ProviderGemini:
+		return defaultGeminiModel
+	case ProviderOpenAI:
+		return defaultOpenAIModel
+	case ProviderOllama:
+		return defaultOllamaModel
+	default:
+		return ""
+	}
+}
+
+// NewClient creates the Client for the configured provider.
+func NewClient(ctx context.Context, cfg Config) (Client, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderGemini:
+		return NewGeminiClient(ctx, cfg.APIKey, cfg.Endpoint, cfg.ModelName)
+	case ProviderOpenAI:
+		return NewOpenAIClient(cfg.APIKey, cfg.Endpoint, cfg.ModelName)
+	case ProviderOllama:
+		return NewOllamaClient(cfg.Endpoint, cfg.ModelName)
+	case ProviderAuto:
+		return NewAutoClient(), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider: %s", cfg.Provider)
+	}
 }
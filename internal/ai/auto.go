@@ -0,0 +1,411 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Ensure JPEG decoding is available
+	_ "image/png"  // Ensure PNG decoding is available
+	"math"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+// AutoClient is a deterministic, model-free Client. It derives parameters from
+// statistics of the decoded preview: a luminance histogram for exposure,
+// whites and blacks, gray-world / white-patch estimation for white balance and
+// HSV saturation for vibrance. With reference images it matches their
+// brightness, contrast, color cast and saturation instead. It needs no network access, which makes it the
+// offline and CI baseline and a sanity reference for the LLM backends.
+type AutoClient struct{}
+
+// NewAutoClient creates an AutoClient.
+func NewAutoClient() *AutoClient {
+	return &AutoClient{}
+}
+
+// neutralTemperature is the Kelvin value a correction starts from when the
+// as-shot white balance of the file is unknown.
+const neutralTemperature = 5500
+
+// castTolerance is the largest red/blue gain ratio deviation, as a natural
+// log, left uncorrected: the preview is rendered at the as-shot white balance,
+// which is then kept.
+const castTolerance = 0.05
+
+// imageStats summarizes a preview for the auto grader.
+type imageStats struct {
+	Histogram [256]int
+	Pixels    int
+
+	LowPercentile  int     // 1st percentile of luma (0-255)
+	Median         int     // 50th percentile of luma
+	HighPercentile int     // 99th percentile of luma
+	StdDev         float64 // luma standard deviation (0-255)
+	Clipped        float64 // fraction of pixels at or above 250
+	Crushed        float64 // fraction of pixels at or below 5
+
+	// White balance gains relative to green, estimated from the scene.
+	RedGain  float64
+	BlueGain float64
+
+	MeanSaturation float64 // mean HSV saturation (0-1)
+}
+
+// analyzeImage decodes the preview and computes its statistics, sampling at
+// most ~512 pixels along the long edge.
+func analyzeImage(imageData []byte) (*imageStats, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode preview: %w", err)
+	}
+
+	b := img.Bounds()
+	step := 1
+	if long := max(b.Dx(), b.Dy()); long > 512 {
+		step = long / 512
+	}
+
+	stats := &imageStats{}
+	var sumR, sumG, sumB, sumSat, sumL, sumL2 float64
+	type px struct{ r, g, b, l float64 }
+	var samples []px
+
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r16, g16, b16, _ := img.At(x, y).RGBA()
+			r, g, bl := float64(r16)/65535, float64(g16)/65535, float64(b16)/65535
+
+			l := 0.2126*r + 0.7152*g + 0.0722*bl
+			bin := int(math.Round(l * 255))
+			stats.Histogram[bin]++
+			stats.Pixels++
+			sumL += l * 255
+			sumL2 += (l * 255) * (l * 255)
+
+			lr, lg, lb := srgbToLinear(r), srgbToLinear(g), srgbToLinear(bl)
+			sumR += lr
+			sumG += lg
+			sumB += lb
+			samples = append(samples, px{lr, lg, lb, l})
+
+			maxC := math.Max(r, math.Max(g, bl))
+			if maxC > 0 {
+				sumSat += (maxC - math.Min(r, math.Min(g, bl))) / maxC
+			}
+		}
+	}
+	if stats.Pixels == 0 {
+		return nil, fmt.Errorf("preview has no pixels")
+	}
+
+	n := float64(stats.Pixels)
+	mean := sumL / n
+	stats.StdDev = math.Sqrt(math.Max(sumL2/n-mean*mean, 0))
+	stats.MeanSaturation = sumSat / n
+	stats.LowPercentile = stats.percentile(0.01)
+	stats.Median = stats.percentile(0.50)
+	stats.HighPercentile = stats.percentile(0.99)
+	for i := 250; i < 256; i++ {
+		stats.Clipped += float64(stats.Histogram[i]) / n
+	}
+	for i := 0; i <= 5; i++ {
+		stats.Crushed += float64(stats.Histogram[i]) / n
+	}
+
+	// Gray world assumes the scene averages to neutral. It fails for scenes
+	// dominated by one color (a forest, a sunset), where the brightest pixels
+	// (white patch) are a better reference.
+	refR, refG, refB := sumR, sumG, sumB
+	if stats.MeanSaturation > 0.35 {
+		threshold := float64(stats.HighPercentile) / 255
+		var wr, wg, wb float64
+		for _, p := range samples {
+			if p.l >= threshold && p.l < 0.98 {
+				wr += p.r
+				wg += p.g
+				wb += p.b
+			}
+		}
+		if wr > 0 && wg > 0 && wb > 0 {
+			refR, refG, refB = wr, wg, wb
+		}
+	}
+	stats.RedGain, stats.BlueGain = 1, 1
+	if refR > 0 && refB > 0 {
+		stats.RedGain = refG / refR
+		stats.BlueGain = refG / refB
+	}
+
+	return stats, nil
+}
+
+// percentile returns the luma value below which fraction p of the pixels fall.
+func (s *imageStats) percentile(p float64) int {
+	target := int(math.Ceil(p * float64(s.Pixels)))
+	count := 0
+	for i, c := range s.Histogram {
+		count += c
+		if count >= target {
+			return i
+		}
+	}
+	return 255
+}
+
+// look is the rendering the auto grader aims for. Without reference images it
+// is a neutral, mid-toned rendering; with references it is measured from them.
+type look struct {
+	Median     float64 // luma median (0-1)
+	StdDev     float64 // luma standard deviation (0-255)
+	Saturation float64 // mean HSV saturation (0-1)
+
+	// Color cast as white balance gains; 1 means neutral.
+	RedGain  float64
+	BlueGain float64
+}
+
+var neutralLook = look{Median: 0.46, StdDev: 55, Saturation: 0.35, RedGain: 1, BlueGain: 1}
+
+// referenceLook averages the statistics of the reference images, keeping
+// their color cast instead of neutralizing it.
+func referenceLook(refs [][]byte) (look, error) {
+	if len(refs) == 0 {
+		return neutralLook, nil
+	}
+	var l look
+	for i, ref := range refs {
+		stats, err := analyzeImage(ref)
+		if err != nil {
+			return look{}, fmt.Errorf("reference image %d: %w", i+1, err)
+		}
+		l.Median += float64(stats.Median) / 255
+		l.StdDev += stats.StdDev
+		l.Saturation += stats.MeanSaturation
+		l.RedGain += stats.RedGain
+		l.BlueGain += stats.BlueGain
+	}
+	n := float64(len(refs))
+	return look{l.Median / n, l.StdDev / n, l.Saturation / n, l.RedGain / n, l.BlueGain / n}, nil
+}
+
+// exposureEV returns the exposure correction in stops that brings the median
+// luma to the target's, limited to +/-2 EV and applied conservatively.
+func (s *imageStats) exposureEV(target look) float64 {
+	median := math.Max(float64(s.Median), 1) / 255
+	ev := math.Log2(srgbToLinear(target.Median) / srgbToLinear(median))
+	return math.Round(clampF(ev*0.7, -2, 2)*100) / 100
+}
+
+// whiteBalance returns the temperature and tint corrections for the cast
+// measured in the preview. The preview is rendered at the as-shot white
+// balance, so the correction is applied to asShot (neutralTemperature when
+// unknown), and a preview without a noticeable cast keeps As Shot (0, 0).
+// A scene with a blue cast (blue gain < red gain) needs a warmer setting.
+// The target's own cast is left in place.
+func (s *imageStats) whiteBalance(target look, asShot int) (temperature, tint int) {
+	ratio := (s.RedGain / target.RedGain) / (s.BlueGain / target.BlueGain)
+	tint = s.tint(target)
+	if math.Abs(math.Log(ratio)) < castTolerance && clampI(tint, -3, 3) == tint {
+		return 0, 0
+	}
+	base := float64(asShot)
+	if base <= 0 {
+		base = neutralTemperature
+	}
+	k := base * math.Pow(ratio, 0.6)
+	return int(math.Round(clampF(k, 2500, 12000)/50) * 50), tint
+}
+
+// tint returns the green/magenta correction on Lightroom's -150..150 scale.
+// A green cast (red and blue gains above 1) is corrected with positive tint.
+func (s *imageStats) tint(target look) int {
+	magenta := (s.RedGain/target.RedGain+s.BlueGain/target.BlueGain)/2 - 1
+	return int(math.Round(clampF(magenta*60, -40, 40)))
+}
+
+// noiseReduction picks a luminance noise reduction amount from the ISO.
+func noiseReduction(iso int) int {
+	switch {
+	case iso >= 6400:
+		return 35
+	case iso >= 3200:
+		return 25
+	case iso >= 1600:
+		return 15
+	case iso >= 800:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// isMonochrome reports whether the style is black and white or pins
+// saturation to -100.
+func isMonochrome(opts AnalysisOptions) bool {
+	def := opts.StyleDefinition()
+	b, ok := def.Bounds.LR["saturation"]
+	return def.Monochrome || ok && b.Max != nil && *b.Max <= -100
+}
+
+func (c *AutoClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.GradingParams, error) {
+	stats, err := analyzeImage(imageData)
+	if err != nil {
+		return nil, err
+	}
+	target, err := referenceLook(opts.ReferenceImages)
+	if err != nil {
+		return nil, err
+	}
+
+	temperature, tint := stats.whiteBalance(target, metadata.ColorTemperature)
+	params := &models.GradingParams{
+		Exposure2012:        stats.exposureEV(target),
+		Temperature:         temperature,
+		Tint:                tint,
+		Sharpness:           40,
+		LuminanceSmoothing:  noiseReduction(metadata.ISO),
+		ColorNoiseReduction: 25,
+	}
+
+	// Stretch the tonal range towards the ends of the histogram.
+	if stats.HighPercentile < 235 {
+		params.Whites2012 = clampI((235-stats.HighPercentile)/2, 0, 40)
+	}
+	if stats.Clipped > 0.02 {
+		params.Highlights2012 = -clampI(int(stats.Clipped*1000), 10, 60)
+	}
+	if stats.LowPercentile > 20 {
+		params.Blacks2012 = -clampI((stats.LowPercentile-20)/2, 0, 40)
+	}
+	if stats.Crushed > 0.02 {
+		params.Shadows2012 = clampI(int(stats.Crushed*1000), 10, 50)
+	}
+
+	// Flat images (low luma spread) get contrast, harsh ones lose some.
+	params.Contrast2012 = clampI(int((target.StdDev-stats.StdDev)*0.6), -20, 25)
+
+	params.Vibrance = clampI(int((target.Saturation-stats.MeanSaturation)*100), -20, 30)
+
+	// In a series, color and contrast come from the shared base so frames
+	// match; exposure stays per photo to even out brightness.
+	if base := opts.Series; base != nil && base.LR != nil {
+		params.Temperature = base.LR.Temperature
+		params.Tint = base.LR.Tint
+		params.Contrast2012 = base.LR.Contrast2012
+		params.Vibrance = base.LR.Vibrance
+		params.Saturation = base.LR.Saturation
+	}
+
+	if isMonochrome(opts) {
+		params.Saturation = -100
+		params.Vibrance = 0
+		params.ConvertToGrayscale = true
+	}
+
+	return params, nil
+}
+
+func (c *AutoClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.PP3Params, error) {
+	stats, err := analyzeImage(imageData)
+	if err != nil {
+		return nil, err
+	}
+	target, err := referenceLook(opts.ReferenceImages)
+	if err != nil {
+		return nil, err
+	}
+
+	// RT renders darker than Lightroom and expects a positive base compensation.
+	temperature, tint := stats.whiteBalance(target, metadata.ColorTemperature)
+	params := &models.PP3Params{
+		Compensation:           math.Round(clampF(0.45+stats.exposureEV(target)*0.8, 0.25, 1.5)*100) / 100,
+		Contrast:               clampI(int((target.StdDev-stats.StdDev)*0.5), -20, 25),
+		Temperature:            temperature,
+		Tint:                   math.Round(clampF(1-float64(tint)/400, 0.9, 1.1)*1000) / 1000,
+		LabContrast:            15,
+		LabChromaticity:        clampI(int((target.Saturation-stats.MeanSaturation)*80)+15, 0, 40),
+		SharpenMicroUniformity: 50,
+		NRLuminance:            noiseReduction(metadata.ISO),
+		NRChrominance:          15,
+		ColorToningBalance:     50,
+	}
+	if stats.Clipped > 0.02 {
+		params.HighlightCompr = clampI(int(stats.Clipped*2000), 20, 150)
+	}
+	if stats.Crushed > 0.02 {
+		params.ShadowRecovery = clampI(int(stats.Crushed*1000), 10, 50)
+	}
+	params.VibPastels = clampI(int((target.Saturation-stats.MeanSaturation)*100), -20, 30)
+
+	if base := opts.Series; base != nil && base.PP3 != nil {
+		params.Temperature = base.PP3.Temperature
+		params.Tint = base.PP3.Tint
+		params.Contrast = base.PP3.Contrast
+		params.LabChromaticity = base.PP3.LabChromaticity
+		params.VibPastels = base.PP3.VibPastels
+		params.Saturation = base.PP3.Saturation
+	}
+
+	if isMonochrome(opts) {
+		params.Saturation = -100
+		params.LabChromaticity = 0
+		params.VibPastels = 0
+		params.BWEnabled = true
+	}
+
+	return params, nil
+}
+
+// sceneRules map image statistics to a scene and the style that suits it,
+// checked in order. Without a model only scenes with a clear tonal or color
+// signature can be told apart; everything else gets the default style.
+var sceneRules = []struct {
+	scene, style string
+	match        func(s *imageStats) bool
+}{
+	{"night city", "cinematic", func(s *imageStats) bool { return s.Median < 50 && s.HighPercentile >= 200 }},
+	{"snow", "snow", func(s *imageStats) bool { return s.Median >= 170 && s.MeanSaturation < 0.15 }},
+	{"golden hour", "golden-hour", func(s *imageStats) bool { return s.MeanSaturation > 0.3 && s.BlueGain > 1.5*s.RedGain }},
+}
+
+func (c *AutoClient) ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no styles to choose from")
+	}
+	stats, err := analyzeImage(imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range sceneRules {
+		if !rule.match(stats) {
+			continue
+		}
+		if name, ok := findStyle(candidates, rule.style); ok {
+			return &models.StyleChoice{Scene: rule.scene, Style: name}, nil
+		}
+	}
+	if name, ok := findStyle(candidates, style.Default); ok {
+		return &models.StyleChoice{Scene: "general", Style: name}, nil
+	}
+	return &models.StyleChoice{Scene: "general", Style: candidates[0].Name}, nil
+}
+
+// srgbToLinear converts a gamma-encoded sRGB component (0-1) to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func clampF(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+func clampI(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
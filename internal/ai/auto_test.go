@@ -0,0 +1,208 @@
+package ai_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"reflect"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+// solidJPEG encodes a 64x48 JPEG with a horizontal gradient around c, so the
+// histogram has some spread.
+func solidJPEG(t *testing.T, c color.RGBA) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			d := uint8(x / 4)
+			img.Set(x, y, color.RGBA{R: c.R + d, G: c.G + d, B: c.B + d, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAutoClient_Exposure(t *testing.T) {
+	client := ai.NewAutoClient()
+	ctx := context.Background()
+
+	dark, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 30, G: 30, B: 30}), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dark.Exposure2012 <= 0.5 {
+		t.Errorf("dark image should be brightened, got exposure %v", dark.Exposure2012)
+	}
+
+	bright, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 200, G: 200, B: 200}), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bright.Exposure2012 >= 0 {
+		t.Errorf("bright image should be darkened, got exposure %v", bright.Exposure2012)
+	}
+}
+
+func TestAutoClient_WhiteBalance(t *testing.T) {
+	client := ai.NewAutoClient()
+	ctx := context.Background()
+
+	neutral, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 110, G: 110, B: 110}), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if neutral.Temperature != 0 || neutral.Tint != 0 {
+		t.Errorf("neutral image should keep As Shot, got %dK tint %d", neutral.Temperature, neutral.Tint)
+	}
+
+	// A tungsten raw balanced in camera renders neutral and must not be pulled to daylight.
+	tungsten := models.Metadata{ColorTemperature: 3200}
+	balanced, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 110, G: 110, B: 110}), tungsten, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balanced.Temperature != 0 {
+		t.Errorf("a balanced tungsten shot should keep As Shot, got %dK", balanced.Temperature)
+	}
+	pp3, err := client.AnalyzeImageForPP3(ctx, solidJPEG(t, color.RGBA{R: 110, G: 110, B: 110}), tungsten, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pp3.Temperature != 0 {
+		t.Errorf("the PP3 of a balanced shot should keep the camera white balance, got %dK", pp3.Temperature)
+	}
+
+	blue, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 80, G: 110, B: 150}), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blue.Temperature <= neutral.Temperature {
+		t.Errorf("blue cast should be warmed, got %dK", blue.Temperature)
+	}
+	cast, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 80, G: 110, B: 150}), tungsten, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cast.Temperature <= 3200 || cast.Temperature >= blue.Temperature {
+		t.Errorf("a blue cast should warm the 3200K as shot, got %dK", cast.Temperature)
+	}
+
+	green, err := client.AnalyzeImageLR(ctx, solidJPEG(t, color.RGBA{R: 90, G: 130, B: 90}), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if green.Tint <= 0 {
+		t.Errorf("green cast should be corrected towards magenta, got tint %d", green.Tint)
+	}
+}
+
+func TestAutoClient_PP3AndStyle(t *testing.T) {
+	client := ai.NewAutoClient()
+	img := solidJPEG(t, color.RGBA{R: 120, G: 100, B: 80})
+
+	params, err := client.AnalyzeImageForPP3(context.Background(), img, models.Metadata{ISO: 6400}, ai.AnalysisOptions{Style: "bw-contrast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Saturation != -100 {
+		t.Errorf("monochrome style should desaturate, got %d", params.Saturation)
+	}
+	if params.NRLuminance == 0 {
+		t.Error("high ISO should enable noise reduction")
+	}
+
+	again, _ := client.AnalyzeImageForPP3(context.Background(), img, models.Metadata{ISO: 6400}, ai.AnalysisOptions{Style: "bw-contrast"})
+	if !reflect.DeepEqual(again, params) {
+		t.Error("auto grader should be deterministic")
+	}
+
+	if _, err := client.AnalyzeImageLR(context.Background(), []byte("not an image"), models.Metadata{}, ai.AnalysisOptions{}); err == nil {
+		t.Error("expected an error for undecodable input")
+	}
+}
+
+func TestAutoClient_ReferenceImages(t *testing.T) {
+	client := ai.NewAutoClient()
+	ctx := context.Background()
+	img := solidJPEG(t, color.RGBA{R: 110, G: 110, B: 110})
+
+	plain, err := client.AnalyzeImageLR(ctx, img, models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A dark, warm reference asks for a darker, warmer rendering.
+	ref := solidJPEG(t, color.RGBA{R: 70, G: 50, B: 30})
+	matched, err := client.AnalyzeImageLR(ctx, img, models.Metadata{}, ai.AnalysisOptions{ReferenceImages: [][]byte{ref}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched.Exposure2012 >= plain.Exposure2012 {
+		t.Errorf("expected lower exposure to match the reference, got %.2f vs %.2f", matched.Exposure2012, plain.Exposure2012)
+	}
+	if matched.Temperature <= plain.Temperature {
+		t.Errorf("expected a warmer temperature to match the reference, got %dK vs %dK", matched.Temperature, plain.Temperature)
+	}
+
+	bad := ai.AnalysisOptions{ReferenceImages: [][]byte{[]byte("not an image")}}
+	if _, err := client.AnalyzeImageForPP3(ctx, img, models.Metadata{}, bad); err == nil {
+		t.Error("expected an error for an undecodable reference")
+	}
+}
+
+func TestAutoClient_Series(t *testing.T) {
+	client := ai.NewAutoClient()
+	base := &ai.SeriesBase{LR: &models.GradingParams{Temperature: 4200, Tint: 8, Vibrance: 12}}
+
+	for _, c := range []color.RGBA{{R: 80, G: 110, B: 150}, {R: 150, G: 110, B: 80}} {
+		params, err := client.AnalyzeImageLR(context.Background(), solidJPEG(t, c), models.Metadata{}, ai.AnalysisOptions{Series: base})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params.Temperature != 4200 || params.Tint != 8 || params.Vibrance != 12 {
+			t.Errorf("series photos should share the base color, got %dK tint %d vibrance %d", params.Temperature, params.Tint, params.Vibrance)
+		}
+	}
+}
+
+func TestAutoClient_ClassifyStyle(t *testing.T) {
+	client := ai.NewAutoClient()
+	ctx := context.Background()
+	candidates := style.Builtin().List()
+
+	snow, err := client.ClassifyStyle(ctx, solidJPEG(t, color.RGBA{R: 215, G: 218, B: 222}), models.Metadata{}, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snow.Style != "snow" {
+		t.Errorf("bright, colorless image should get the snow style, got %+v", snow)
+	}
+
+	plain, err := client.ClassifyStyle(ctx, solidJPEG(t, color.RGBA{R: 110, G: 110, B: 110}), models.Metadata{}, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Style != style.Default {
+		t.Errorf("an unremarkable image should get the default style, got %+v", plain)
+	}
+
+	// Only styles from the catalog may be picked.
+	vivid, _ := style.Builtin().Get("vivid")
+	choice, err := client.ClassifyStyle(ctx, solidJPEG(t, color.RGBA{R: 215, G: 218, B: 222}), models.Metadata{}, []*style.Style{vivid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if choice.Style != "vivid" {
+		t.Errorf("expected the only candidate, got %+v", choice)
+	}
+}
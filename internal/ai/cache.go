@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+// CacheClient is a content-addressed disk cache in front of a Client.
+// Entries are keyed by the preview bytes, the metadata that goes into the
+// prompt, the analysis options (style, user prompt, ...), the model identity
+// and the response schema, so any change to the input or to the expected
+// output shape results in a fresh call.
+type CacheClient struct {
+	inner   Client
+	dir     string
+	model   string
+	refresh bool
+}
+
+// NewCacheClient wraps inner with a cache stored under dir. model identifies
+// the backend (e.g. "gemini/gemini-2.5-flash"). With refresh set, existing
+// entries are ignored and overwritten.
+func NewCacheClient(inner Client, dir, model string, refresh bool) *CacheClient {
+	return &CacheClient{inner: inner, dir: dir, model: model, refresh: refresh}
+}
+
+// DefaultCacheDir returns the per-user cache location, e.g. ~/.cache/sidelight/ai.
+func DefaultCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "sidelight", "ai"), nil
+}
+
+func (c *CacheClient) Close() error {
+	if closer, ok := c.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *CacheClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.GradingParams, error) {
+	return cached(c, c.key("lr", lrSchema, imageData, metadata, opts), func() (*models.GradingParams, error) {
+		return c.inner.AnalyzeImageLR(ctx, imageData, metadata, opts)
+	})
+}
+
+func (c *CacheClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.PP3Params, error) {
+	return cached(c, c.key("pp3", pp3Schema, imageData, metadata, opts), func() (*models.PP3Params, error) {
+		return c.inner.AnalyzeImageForPP3(ctx, imageData, metadata, opts)
+	})
+}
+
+func (c *CacheClient) ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error) {
+	classifier, err := styleClassifier(c.inner)
+	if err != nil {
+		return nil, err
+	}
+	// The choice depends on the catalog offered, not on any grading option.
+	catalog, _ := json.Marshal(candidates)
+	return cached(c, c.key("style", styleSchema, imageData, metadata, AnalysisOptions{}, string(catalog)), func() (*models.StyleChoice, error) {
+		return classifier.ClassifyStyle(ctx, imageData, metadata, candidates)
+	})
+}
+
+// key derives the cache key for one call. extra adds inputs that are not
+// part of the analysis options.
+func (c *CacheClient) key(kind string, schema *responseSchema, imageData []byte, metadata models.Metadata, opts AnalysisOptions, extra ...string) string {
+	imageHash := sha256.Sum256(imageData)
+	metadataJSON, _ := json.Marshal(metadata)
+	optsJSON, _ := json.Marshal(opts)
+
+	parts := []string{kind, c.model, hex.EncodeToString(imageHash[:]), string(metadataJSON), string(optsJSON), schema.describe()}
+	for _, ref := range opts.ReferenceImages {
+		refHash := sha256.Sum256(ref)
+		parts = append(parts, hex.EncodeToString(refHash[:]))
+	}
+	parts = append(parts, extra...)
+
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *CacheClient) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// cached returns the stored result for key, or runs call and stores its result.
+// Cache I/O problems never fail the analysis; they only cost a cache miss.
+func cached[T any](c *CacheClient, key string, call func() (*T, error)) (*T, error) {
+	path := c.path(key)
+	if !c.refresh {
+		if data, err := os.ReadFile(path); err == nil {
+			var result T
+			if err := json.Unmarshal(data, &result); err == nil {
+				return &result, nil
+			}
+		}
+	}
+
+	result, err := call()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(result); err == nil {
+		_ = writeFileAtomic(path, data)
+	}
+	return result, nil
+}
+
+// writeFileAtomic writes data via a temp file and rename, so concurrent
+// workers and interrupted runs never leave a truncated entry behind.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+	return nil
+}
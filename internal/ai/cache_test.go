@@ -0,0 +1,64 @@
+package ai_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+func TestCacheClient(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &flakyClient{}
+	client := ai.NewCacheClient(inner, dir, "test/model", false)
+
+	opts := ai.AnalysisOptions{Style: "film", UserPrompt: "warmer"}
+	first, err := client.AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls.Load() != 1 {
+		t.Errorf("expected the second call to hit the cache, got %d inner calls", inner.calls.Load())
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("cached result differs: %+v vs %+v", first, second)
+	}
+
+	// Any part of the key changing is a miss.
+	client.AnalyzeImageLR(ctx, []byte("other preview"), models.Metadata{}, opts)
+	client.AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{}, ai.AnalysisOptions{Style: "bw", UserPrompt: "warmer"})
+	client.AnalyzeImageForPP3(ctx, []byte("preview"), models.Metadata{}, opts)
+	ai.NewCacheClient(inner, dir, "test/other-model", false).AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{}, opts)
+	withRef := opts
+	withRef.ReferenceImages = [][]byte{[]byte("reference")}
+	client.AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{}, withRef)
+	client.AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{Make: "FUJIFILM"}, opts)
+	if inner.calls.Load() != 7 {
+		t.Errorf("expected 7 inner calls, got %d", inner.calls.Load())
+	}
+
+	// Refresh recomputes even when an entry exists.
+	ai.NewCacheClient(inner, dir, "test/model", true).AnalyzeImageLR(ctx, []byte("preview"), models.Metadata{}, opts)
+	if inner.calls.Load() != 8 {
+		t.Errorf("expected refresh to bypass the cache, got %d inner calls", inner.calls.Load())
+	}
+}
+
+func TestCacheClient_DoesNotCacheErrors(t *testing.T) {
+	inner := &flakyClient{failures: 1, err: errBoom}
+	client := ai.NewCacheClient(inner, t.TempDir(), "test/model", false)
+
+	if _, err := client.AnalyzeImageForPP3(context.Background(), []byte("x"), models.Metadata{}, ai.AnalysisOptions{}); err == nil {
+		t.Fatal("expected the first call to fail")
+	}
+	if _, err := client.AnalyzeImageForPP3(context.Background(), []byte("x"), models.Metadata{}, ai.AnalysisOptions{}); err != nil {
+		t.Fatalf("expected the failure not to be cached, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+// StyleClassifier is implemented by clients that can pick a grading style for
+// a photo. It is a quick first pass before grading, used for --style auto.
+type StyleClassifier interface {
+	// ClassifyStyle names the scene and picks the best of candidates for it.
+	// The returned Style is always the name of one of the candidates.
+	ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error)
+}
+
+var styleSchema = schemaFor(reflect.TypeOf(models.StyleChoice{}))
+
+const classifyInstruction = `You are a photo editor choosing a grading style.
+Look at the photo, decide what kind of scene it shows (portrait, landscape, food,
+night city, snow, street, product, ...) and pick the style from the list below that
+suits it best. When nothing stands out, pick the most neutral style.`
+
+// classifyPrompt builds the text prompt for style selection.
+func classifyPrompt(metadata models.Metadata, candidates []*style.Style) string {
+	var list strings.Builder
+	for _, s := range candidates {
+		fmt.Fprintf(&list, "- %s: %s\n", s.Name, s.Description)
+	}
+
+	return fmt.Sprintf(`%s
+
+Schema:
+%s
+%s
+
+Available Styles:
+%s
+Output ONLY the JSON object.`, classifyInstruction, styleSchema.describe(), metadataSection(metadata), list.String())
+}
+
+// decodeStyleChoice parses a style selection reply. A style that is not among
+// the candidates is a transient failure, like any other unusable reply.
+func decodeStyleChoice(text string, candidates []*style.Style) (*models.StyleChoice, error) {
+	var choice models.StyleChoice
+	if err := decodeResponse(text, styleSchema, &choice); err != nil {
+		return nil, err
+	}
+	name, ok := findStyle(candidates, choice.Style)
+	if !ok {
+		return nil, transient(fmt.Errorf("%w: AI picked unknown style %q", ErrMalformedResponse, choice.Style))
+	}
+	choice.Style = name
+	return &choice, nil
+}
+
+// findStyle returns the canonical name of the candidate called name.
+func findStyle(candidates []*style.Style, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for _, s := range candidates {
+		if strings.EqualFold(s.Name, name) {
+			return s.Name, true
+		}
+	}
+	return "", false
+}
+
+// styleClassifier returns inner as a StyleClassifier, for the wrapping clients.
+func styleClassifier(inner Client) (StyleClassifier, error) {
+	classifier, ok := inner.(StyleClassifier)
+	if !ok {
+		return nil, fmt.Errorf("AI client does not support automatic style selection")
+	}
+	return classifier, nil
+}
@@ -0,0 +1,221 @@
+package ai
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"sync"
+
+	"sidelight/pkg/models"
+)
+
+// unstableFraction is the share of a field's schema range the samples may
+// disagree by before the field is reported as unstable.
+const unstableFraction = 0.1
+
+// Consensus runs call opts.Samples times in parallel and combines the results
+// with Combine. Each call gets its own Sample index, so cached samples stay
+// distinct. With fewer than two samples call runs once and no spread is
+// reported. The first failing sample cancels the others.
+func Consensus[T any](ctx context.Context, opts AnalysisOptions, call func(context.Context, AnalysisOptions) (*T, error)) (*T, []models.ParamSpread, error) {
+	if opts.Samples < 2 {
+		result, err := call(ctx, opts)
+		return result, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	samples := make([]*T, opts.Samples)
+	errs := make([]error, opts.Samples)
+	var wg sync.WaitGroup
+	for i := range samples {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o := opts
+			o.Samples, o.Sample = 0, i
+			samples[i], errs[i] = call(ctx, o)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Report the root cause rather than the cancellations it triggered.
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	result, spread := Combine(samples)
+	return result, spread, nil
+}
+
+// Combine merges parameter sets field by field: numbers take the median,
+// booleans the majority, curves a point-wise median and anything else the
+// first sample that sets it. It also returns the
+// spread of every numeric field the samples disagreed on.
+func Combine[T any](samples []*T) (*T, []models.ParamSpread) {
+	result := new(T)
+	if len(samples) == 0 {
+		return result, nil
+	}
+
+	dst := reflect.ValueOf(result).Elem()
+	var spread []models.ParamSpread
+	for _, spec := range models.Fields(dst.Type()) {
+		values := make([]reflect.Value, len(samples))
+		for i, s := range samples {
+			values[i] = reflect.ValueOf(s).Elem().FieldByIndex(spec.Index)
+		}
+		field := dst.FieldByIndex(spec.Index)
+
+		switch field.Kind() {
+		case reflect.Int, reflect.Float64:
+			nums := make([]float64, len(values))
+			for i, v := range values {
+				nums[i] = toFloat(v)
+			}
+			m := median(nums)
+			if field.Kind() == reflect.Int {
+				field.SetInt(int64(math.Round(m)))
+			} else {
+				field.SetFloat(m)
+			}
+
+			lo, hi := slices.Min(nums), slices.Max(nums)
+			if lo != hi {
+				spread = append(spread, models.ParamSpread{
+					Field:    spec.Name,
+					Min:      lo,
+					Max:      hi,
+					Median:   m,
+					Unstable: spec.HasRange && hi-lo > (spec.Max-spec.Min)*unstableFraction,
+				})
+			}
+		case reflect.Bool:
+			yes := 0
+			for _, v := range values {
+				if v.Bool() {
+					yes++
+				}
+			}
+			field.SetBool(yes*2 > len(values))
+		case reflect.Slice:
+			if field.Type() == reflect.TypeOf([][]float64(nil)) {
+				if curve, ok := combineCurves(values); ok {
+					field.Set(reflect.ValueOf(curve))
+				}
+				break
+			}
+			// Masks cannot be averaged either; use the first sample that has any.
+			for _, v := range values {
+				if v.Len() > 0 {
+					field.Set(v)
+					break
+				}
+			}
+		case reflect.Ptr:
+			// Descriptions cannot be averaged; use the first sample that has one.
+			for _, v := range values {
+				if !v.IsNil() {
+					field.Set(v)
+					break
+				}
+			}
+		default:
+			field.Set(values[0])
+		}
+	}
+	return result, spread
+}
+
+// combineCurves merges [][]float64 control point lists. A curve is kept only
+// if most samples set one. The result uses the x positions of the median-sized
+// curve, with y the median of all curves interpolated there.
+func combineCurves(values []reflect.Value) ([][]float64, bool) {
+	var curves [][][]float64
+	for _, v := range values {
+		if c, ok := v.Interface().([][]float64); ok && len(c) > 0 {
+			curves = append(curves, c)
+		}
+	}
+	if len(curves)*2 <= len(values) {
+		return nil, false
+	}
+
+	sorted := slices.Clone(curves)
+	slices.SortStableFunc(sorted, func(a, b [][]float64) int { return len(a) - len(b) })
+	base := sorted[len(sorted)/2]
+
+	out := make([][]float64, 0, len(base))
+	for _, p := range base {
+		if len(p) < 2 {
+			continue
+		}
+		ys := make([]float64, len(curves))
+		for i, c := range curves {
+			ys[i] = interpolate(c, p[0])
+		}
+		out = append(out, []float64{p[0], median(ys)})
+	}
+	return out, true
+}
+
+// interpolate evaluates a control point list linearly at x.
+func interpolate(curve [][]float64, x float64) float64 {
+	var pts [][]float64
+	for _, p := range curve {
+		if len(p) >= 2 {
+			pts = append(pts, p)
+		}
+	}
+	if len(pts) == 0 {
+		return x
+	}
+	slices.SortFunc(pts, func(a, b []float64) int { return cmp.Compare(a[0], b[0]) })
+
+	if x <= pts[0][0] {
+		return pts[0][1]
+	}
+	for i := 1; i < len(pts); i++ {
+		if x <= pts[i][0] {
+			a, b := pts[i-1], pts[i]
+			if b[0] == a[0] {
+				return b[1]
+			}
+			return a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])
+		}
+	}
+	return pts[len(pts)-1][1]
+}
+
+func median(values []float64) float64 {
+	s := slices.Clone(values)
+	slices.Sort(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func toFloat(v reflect.Value) float64 {
+	if v.Kind() == reflect.Int {
+		return float64(v.Int())
+	}
+	return v.Float()
+}
@@ -0,0 +1,124 @@
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+func TestCombine_Median(t *testing.T) {
+	samples := []*models.GradingParams{
+		{Exposure2012: 0.3, Contrast2012: 10, Temperature: 5400},
+		{Exposure2012: 2.5, Contrast2012: 14, Temperature: 5600}, // outlier
+		{Exposure2012: 0.4, Contrast2012: 11, Temperature: 5500},
+	}
+	params, spread := ai.Combine(samples)
+	if params.Exposure2012 != 0.4 || params.Contrast2012 != 11 || params.Temperature != 5500 {
+		t.Errorf("expected per-field medians, got %+v", params)
+	}
+
+	byField := map[string]models.ParamSpread{}
+	for _, s := range spread {
+		byField[s.Field] = s
+	}
+	if s := byField["exposure"]; !s.Unstable || s.Min != 0.3 || s.Max != 2.5 {
+		t.Errorf("exposure should be reported as unstable, got %+v", s)
+	}
+	if byField["contrast"].Unstable {
+		t.Error("a 4 point contrast spread is not unstable")
+	}
+	if _, ok := byField["saturation"]; ok {
+		t.Error("fields the samples agree on should not be reported")
+	}
+}
+
+func TestCombine_Curves(t *testing.T) {
+	samples := []*models.PP3Params{
+		{SharpenEnabled: true, ToneCurve: [][]float64{{0, 0}, {0.5, 0.6}, {1, 1}}},
+		{SharpenEnabled: true, ToneCurve: [][]float64{{0, 0}, {0.5, 0.5}, {1, 1}}},
+		{ToneCurve: [][]float64{{0, 0.1}, {1, 1}}, LCurve: [][]float64{{0, 0}, {1, 1}}},
+	}
+	params, _ := ai.Combine(samples)
+
+	if !params.SharpenEnabled {
+		t.Error("booleans should follow the majority")
+	}
+	want := [][]float64{{0, 0}, {0.5, 0.55}, {1, 1}}
+	if !reflect.DeepEqual(params.ToneCurve, want) {
+		t.Errorf("expected point-wise median curve %v, got %v", want, params.ToneCurve)
+	}
+	if params.LCurve != nil {
+		t.Error("a curve set by only one of three samples should be dropped")
+	}
+}
+
+func TestCombine_LocalAdjustments(t *testing.T) {
+	sky := []models.LocalAdjustment{{Type: models.MaskSky, Exposure: -0.5}}
+	samples := []*models.GradingParams{{}, {LocalAdjustments: sky}, {LocalAdjustments: []models.LocalAdjustment{{Type: models.MaskSubject}}}}
+	params, _ := ai.Combine(samples)
+
+	if !reflect.DeepEqual(params.LocalAdjustments, sky) {
+		t.Errorf("masks should come from the first sample that has any, got %+v", params.LocalAdjustments)
+	}
+}
+
+// sampleClient returns a different exposure per sample index.
+type sampleClient struct {
+	mu   sync.Mutex
+	seen []int
+	err  error
+}
+
+func (c *sampleClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+	c.mu.Lock()
+	c.seen = append(c.seen, opts.Sample)
+	c.mu.Unlock()
+	if c.err != nil && opts.Sample == 1 {
+		return nil, c.err
+	}
+	return &models.GradingParams{Exposure2012: float64(opts.Sample), Temperature: 5500}, nil
+}
+
+func (c *sampleClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (*models.PP3Params, error) {
+	return &models.PP3Params{}, nil
+}
+
+func TestConsensus(t *testing.T) {
+	client := &sampleClient{}
+	call := func(ctx context.Context, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+		return client.AnalyzeImageLR(ctx, nil, models.Metadata{}, opts)
+	}
+
+	params, spread, err := ai.Consensus(context.Background(), ai.AnalysisOptions{Samples: 5}, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.seen) != 5 {
+		t.Fatalf("expected 5 calls, got %d", len(client.seen))
+	}
+	seen := map[int]bool{}
+	for _, s := range client.seen {
+		seen[s] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("each sample needs its own index, got %v", client.seen)
+	}
+	if params.Exposure2012 != 2 || len(spread) != 1 {
+		t.Errorf("unexpected consensus %+v, spread %+v", params, spread)
+	}
+
+	client = &sampleClient{err: errBoom}
+	if _, _, err := ai.Consensus(context.Background(), ai.AnalysisOptions{Samples: 3}, call); !errors.Is(err, errBoom) {
+		t.Errorf("expected the sample error, got %v", err)
+	}
+
+	client = &sampleClient{}
+	if _, spread, _ := ai.Consensus(context.Background(), ai.AnalysisOptions{}, call); len(client.seen) != 1 || spread != nil {
+		t.Error("without Samples only one call should be made")
+	}
+}
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+)
+
+// Kinds of AI failure, matched with errors.Is. They are independent of
+// TransientError and PermanentError, which only decide whether to retry.
+var (
+	// ErrSafetyBlocked means the provider refused the image or the reply
+	// on content policy grounds.
+	ErrSafetyBlocked = errors.New("blocked by safety filter")
+	// ErrQuota means a rate limit or usage quota was hit.
+	ErrQuota = errors.New("quota exceeded")
+	// ErrMalformedResponse means the reply could not be used: invalid or
+	// truncated JSON, or values that do not match the schema.
+	ErrMalformedResponse = errors.New("malformed AI response")
+	// ErrEmptyResponse means the provider returned no content at all.
+	ErrEmptyResponse = errors.New("empty AI response")
+	// ErrAuth means the credentials were missing, invalid or not allowed
+	// to use the model.
+	ErrAuth = errors.New("authentication failed")
+)
+
+// statusKind maps an HTTP status code to one of the error kinds above, or
+// nil when the status has none.
+func statusKind(code int) error {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuth
+	case http.StatusTooManyRequests:
+		return ErrQuota
+	default:
+		return nil
+	}
+}
+
+// withKind tags err with the kind matching a Google API error's status, so
+// Gemini failures can be told apart like those of the HTTP-based clients.
+func withKind(err error) error {
+	if ae, ok := apierror.FromError(err); ok {
+		if kind := statusKind(ae.HTTPCode()); kind != nil {
+			return fmt.Errorf("%w: %w", kind, err)
+		}
+	}
+	return err
+}
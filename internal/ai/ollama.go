@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+const (
+	defaultOllamaEndpoint = "http://localhost:11434"
+	defaultOllamaModel    = "llava"
+)
+
+// OllamaClient implements Client against a local Ollama server (/api/chat),
+// so grading can run fully offline with a multimodal model such as llava.
+type OllamaClient struct {
+	httpClient *http.Client
+	url        string
+	modelName  string
+}
+
+// NewOllamaClient creates a client for the Ollama chat API at endpoint.
+func NewOllamaClient(endpoint, modelName string) (*OllamaClient, error) {
+	if endpoint == "" {
+		endpoint = defaultOllamaEndpoint
+	}
+	if len(modelName) == 0 {
+		modelName = defaultOllamaModel
+	}
+
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/api/chat") {
+		url += "/api/chat"
+	}
+
+	return &OllamaClient{
+		httpClient: &http.Client{},
+		url:        url,
+		modelName:  modelName,
+	}, nil
+}
+
+func (c *OllamaClient) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   interface{}     `json:"format,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (c *OllamaClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.GradingParams, error) {
+	text, err := c.chat(ctx, opts.images(imageData), lrSchema, lrPrompt(metadata, opts)+
+		"\n\nPlease grade this image and output the result in the specified JSON format.")
+	if err != nil {
+		return nil, err
+	}
+
+	var params models.GradingParams
+	if err := decodeResponse(text, lrSchema, &params); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+func (c *OllamaClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.PP3Params, error) {
+	text, err := c.chat(ctx, opts.images(imageData), pp3Schema, pp3Prompt(metadata, opts)+"\n\nOutput the JSON object now.")
+	if err != nil {
+		return nil, err
+	}
+
+	var params models.PP3Params
+	if err := decodeResponse(text, pp3Schema, &params); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+func (c *OllamaClient) ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error) {
+	text, err := c.chat(ctx, [][]byte{imageData}, styleSchema, classifyPrompt(metadata, candidates))
+	if err != nil {
+		return nil, err
+	}
+	return decodeStyleChoice(text, candidates)
+}
+
+// chat sends a single non-streaming user message with the images attached.
+// Ollama constrains generation to the given JSON schema via the format field.
+func (c *OllamaClient) chat(ctx context.Context, images [][]byte, schema *responseSchema, prompt string) (string, error) {
+	encoded := make([]string, len(images))
+	for i, img := range images {
+		encoded[i] = base64.StdEncoding.EncodeToString(img)
+	}
+
+	body, err := json.Marshal(ollamaRequest{
+		Model: c.modelName,
+		Messages: []ollamaMessage{{
+			Role:    "user",
+			Content: prompt,
+			Images:  encoded,
+		}},
+		Format: schema,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ollama response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError("ollama", resp, strings.TrimSpace(string(respBody)))
+	}
+
+	var out ollamaResponse
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("%w: failed to decode ollama response: %w", ErrMalformedResponse, err)
+	}
+	// Ollama reports only the total prompt size; how many tokens an image
+	// takes depends on the model, so no split is estimated.
+	if out.PromptEvalCount > 0 || out.EvalCount > 0 {
+		recordUsage(ctx, splitInput(c.modelName, out.PromptEvalCount, out.EvalCount, 0))
+	}
+	if out.Error != "" {
+		return "", fmt.Errorf("ollama error: %s", out.Error)
+	}
+	if strings.TrimSpace(out.Message.Content) == "" {
+		return "", transient(fmt.Errorf("%w: empty message returned from ollama (done_reason=%s)", ErrEmptyResponse, out.DoneReason))
+	}
+	return out.Message.Content, nil
+}
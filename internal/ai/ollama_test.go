@@ -0,0 +1,76 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+func TestOllamaClient_AnalyzeImageLR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		var req struct {
+			Model    string `json:"model"`
+			Stream   bool   `json:"stream"`
+			Messages []struct {
+				Images []string `json:"images"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Stream {
+			t.Error("expected a non-streaming request")
+		}
+		if len(req.Messages) != 1 || len(req.Messages[0].Images) != 1 || req.Messages[0].Images[0] != "aW1n" {
+			t.Errorf("expected one base64 image, got %+v", req.Messages)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":       req.Model,
+			"message":     map[string]string{"role": "assistant", "content": mustJSON(t, models.GradingParams{Exposure2012: -0.2, Vibrance: 15, Temperature: 5000})},
+			"done":        true,
+			"done_reason": "stop",
+		})
+	}))
+	defer srv.Close()
+
+	client, err := ai.NewOllamaClient(srv.URL, "llava")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := client.AnalyzeImageLR(context.Background(), []byte("img"), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("AnalyzeImageLR failed: %v", err)
+	}
+	if params.Exposure2012 != -0.2 || params.Vibrance != 15 {
+		t.Errorf("unexpected params: %+v", params)
+	}
+}
+
+func TestConfig_RequiresAPIKey(t *testing.T) {
+	tests := []struct {
+		cfg  ai.Config
+		want bool
+	}{
+		{ai.Config{Provider: ""}, true},
+		{ai.Config{Provider: ai.ProviderGemini}, true},
+		{ai.Config{Provider: ai.ProviderOpenAI}, true},
+		{ai.Config{Provider: ai.ProviderOpenAI, Endpoint: "http://localhost:8080/v1"}, false},
+		{ai.Config{Provider: ai.ProviderOllama}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.RequiresAPIKey(); got != tt.want {
+			t.Errorf("RequiresAPIKey(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
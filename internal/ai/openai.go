@@ -0,0 +1,230 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+const (
+	defaultOpenAIEndpoint = "https://api.openai.com"
+	defaultOpenAIModel    = "gpt-4o-mini"
+)
+
+// OpenAIClient implements Client against any OpenAI-compatible
+// /v1/chat/completions endpoint that accepts image inputs.
+type OpenAIClient struct {
+	httpClient *http.Client
+	apiKey     string
+	url        string
+	modelName  string
+}
+
+// NewOpenAIClient creates a client for an OpenAI-style chat completions API.
+// endpoint may be a bare host ("http://gateway:8000"), a /v1 base URL, or the
+// full chat completions URL.
+func NewOpenAIClient(apiKey, endpoint, modelName string) (*OpenAIClient, error) {
+	if endpoint == "" {
+		endpoint = defaultOpenAIEndpoint
+	}
+	if len(modelName) == 0 {
+		modelName = defaultOpenAIModel
+	}
+
+	return &OpenAIClient{
+		httpClient: &http.Client{},
+		apiKey:     apiKey,
+		url:        chatCompletionsURL(endpoint),
+		modelName:  modelName,
+	}, nil
+}
+
+// chatCompletionsURL normalizes the configured endpoint to the chat completions URL.
+func chatCompletionsURL(endpoint string) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	switch {
+	case strings.HasSuffix(endpoint, "/chat/completions"):
+		return endpoint
+	case strings.HasSuffix(endpoint, "/v1"):
+		return endpoint + "/chat/completions"
+	default:
+		return endpoint + "/v1/chat/completions"
+	}
+}
+
+func (c *OpenAIClient) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema *responseSchema `json:"schema"`
+}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (c *OpenAIClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.GradingParams, error) {
+	text, err := c.complete(ctx, opts.images(imageData), "grading_params", lrSchema, lrPrompt(metadata, opts),
+		"Please grade this image and output the result in the specified JSON format.")
+	if err != nil {
+		return nil, err
+	}
+
+	var params models.GradingParams
+	if err := decodeResponse(text, lrSchema, &params); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+func (c *OpenAIClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.PP3Params, error) {
+	text, err := c.complete(ctx, opts.images(imageData), "pp3_params", pp3Schema, pp3Prompt(metadata, opts), "Output the JSON object now.")
+	if err != nil {
+		return nil, err
+	}
+
+	var params models.PP3Params
+	if err := decodeResponse(text, pp3Schema, &params); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+func (c *OpenAIClient) ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error) {
+	text, err := c.complete(ctx, [][]byte{imageData}, "style_choice", styleSchema, classifyPrompt(metadata, candidates))
+	if err != nil {
+		return nil, err
+	}
+	return decodeStyleChoice(text, candidates)
+}
+
+// complete sends the images plus text prompts, requesting output that matches
+// schema, and returns the text of the first choice.
+func (c *OpenAIClient) complete(ctx context.Context, images [][]byte, schemaName string, schema *responseSchema, prompts ...string) (string, error) {
+	var content []openAIContentPart
+	for _, img := range images {
+		content = append(content, openAIContentPart{
+			Type:     "image_url",
+			ImageURL: &openAIImageURL{URL: "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(img)},
+		})
+	}
+	for _, p := range prompts {
+		content = append(content, openAIContentPart{Type: "text", Text: p})
+	}
+
+	body, err := json.Marshal(openAIRequest{
+		Model:    c.modelName,
+		Messages: []openAIMessage{{Role: "user", Content: content}},
+		ResponseFormat: &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: schemaName, Schema: schema},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode openai request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create openai request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read openai response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError("openai", resp, strings.TrimSpace(string(respBody)))
+	}
+
+	var out openAIResponse
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("%w: failed to decode openai response: %w", ErrMalformedResponse, err)
+	}
+	if out.Usage != nil {
+		recordUsage(ctx, splitInput(c.modelName, out.Usage.PromptTokens, out.Usage.CompletionTokens, openAIImageTokens(images)))
+	}
+	if out.Error != nil {
+		err := fmt.Errorf("openai error (%s): %s", out.Error.Type, out.Error.Message)
+		switch out.Error.Type {
+		case "insufficient_quota":
+			return "", fmt.Errorf("%w: %w", ErrQuota, err)
+		case "authentication_error", "invalid_api_key":
+			return "", fmt.Errorf("%w: %w", ErrAuth, err)
+		}
+		return "", err
+	}
+	if len(out.Choices) == 0 {
+		return "", transient(fmt.Errorf("%w: no choices returned from openai", ErrEmptyResponse))
+	}
+
+	choice := out.Choices[0]
+	if choice.FinishReason == "content_filter" {
+		return "", fmt.Errorf("%w: choice finished with reason: %s", ErrSafetyBlocked, choice.FinishReason)
+	}
+	if strings.TrimSpace(choice.Message.Content) == "" {
+		return "", transient(fmt.Errorf("%w: empty message returned from openai (finish_reason=%s)", ErrEmptyResponse, choice.FinishReason))
+	}
+	return choice.Message.Content, nil
+}
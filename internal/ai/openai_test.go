@@ -0,0 +1,227 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+func newOpenAIStub(t *testing.T, content string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content []struct {
+					Type     string `json:"type"`
+					ImageURL *struct {
+						URL string `json:"url"`
+					} `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Model != "test-model" {
+			t.Errorf("unexpected model %q", req.Model)
+		}
+		if len(req.Messages) == 0 || req.Messages[0].Content[0].ImageURL == nil ||
+			!strings.HasPrefix(req.Messages[0].Content[0].ImageURL.URL, "data:image/jpeg;base64,") {
+			t.Error("expected the first content part to be a data URL image")
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{
+				"message":       map[string]string{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			}},
+		})
+	}))
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestOpenAIClient_AnalyzeImageLR(t *testing.T) {
+	reply := mustJSON(t, models.GradingParams{Exposure2012: 0.35, Contrast2012: 12, Temperature: 5600})
+	srv := newOpenAIStub(t, "```json\n"+reply+"\n```")
+	defer srv.Close()
+
+	client, err := ai.NewOpenAIClient("test-key", srv.URL, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := client.AnalyzeImageLR(context.Background(), []byte("img"), models.Metadata{}, ai.AnalysisOptions{Style: "natural"})
+	if err != nil {
+		t.Fatalf("AnalyzeImageLR failed: %v", err)
+	}
+	if params.Exposure2012 != 0.35 || params.Contrast2012 != 12 || params.Temperature != 5600 {
+		t.Errorf("unexpected params: %+v", params)
+	}
+}
+
+func TestOpenAIClient_AnalyzeImageForPP3(t *testing.T) {
+	srv := newOpenAIStub(t, mustJSON(t, models.PP3Params{Compensation: 0.5, LabChromaticity: 20, Temperature: 5500, Tint: 1.0}))
+	defer srv.Close()
+
+	client, err := ai.NewOpenAIClient("test-key", srv.URL+"/v1", "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := client.AnalyzeImageForPP3(context.Background(), []byte("img"), models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("AnalyzeImageForPP3 failed: %v", err)
+	}
+	if params.Compensation != 0.5 || params.LabChromaticity != 20 {
+		t.Errorf("unexpected params: %+v", params)
+	}
+}
+
+func TestOpenAIClient_ClassifyStyle(t *testing.T) {
+	candidates := style.Builtin().List()
+
+	srv := newOpenAIStub(t, `{"scene": "food", "style": "Food"}`)
+	defer srv.Close()
+	client, err := ai.NewOpenAIClient("test-key", srv.URL, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	choice, err := client.ClassifyStyle(context.Background(), []byte("img"), models.Metadata{}, candidates)
+	if err != nil {
+		t.Fatalf("ClassifyStyle failed: %v", err)
+	}
+	if choice.Style != "food" || choice.Scene != "food" {
+		t.Errorf("unexpected choice %+v", choice)
+	}
+
+	unknown := newOpenAIStub(t, `{"scene": "food", "style": "gourmet"}`)
+	defer unknown.Close()
+	client, _ = ai.NewOpenAIClient("test-key", unknown.URL, "test-model")
+	_, err = client.ClassifyStyle(context.Background(), []byte("img"), models.Metadata{}, candidates)
+	var te *ai.TransientError
+	if !errors.As(err, &te) {
+		t.Errorf("a style outside the catalog should be a transient error, got %v", err)
+	}
+}
+
+func TestOpenAIClient_ReferenceImages(t *testing.T) {
+	var images []string
+	var text string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content []struct {
+					Type     string `json:"type"`
+					Text     string `json:"text"`
+					ImageURL *struct {
+						URL string `json:"url"`
+					} `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, part := range req.Messages[len(req.Messages)-1].Content {
+			if part.ImageURL != nil {
+				images = append(images, part.ImageURL.URL)
+			}
+			text += part.Text
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{
+				"message": map[string]string{"role": "assistant", "content": mustJSON(t, models.GradingParams{Temperature: 5500})},
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := ai.NewOpenAIClient("test-key", srv.URL, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := ai.AnalysisOptions{ReferenceImages: [][]byte{[]byte("ref1"), []byte("ref2")}}
+	if _, err := client.AnalyzeImageLR(context.Background(), []byte("img"), models.Metadata{}, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != 3 {
+		t.Fatalf("expected the photo plus 2 references, got %d images", len(images))
+	}
+	if images[0] != "data:image/jpeg;base64,aW1n" { // "img"
+		t.Errorf("the photo must be sent first, got %s", images[0])
+	}
+	if !strings.Contains(text, "Reference Images") {
+		t.Error("prompt should explain the reference images")
+	}
+}
+
+func TestOpenAIClient_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client, _ := ai.NewOpenAIClient("wrong", srv.URL, "test-model")
+	_, err := client.AnalyzeImageLR(context.Background(), []byte("img"), models.Metadata{}, ai.AnalysisOptions{})
+	if err == nil {
+		t.Fatal("expected an error for a 401 response")
+	}
+	if !errors.Is(err, ai.ErrAuth) {
+		t.Errorf("a 401 response should be ErrAuth, got %v", err)
+	}
+}
+
+func TestOpenAIClient_ErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		response map[string]interface{}
+		want     error
+	}{
+		{"content filter", map[string]interface{}{"choices": []map[string]interface{}{{
+			"message": map[string]string{"content": ""}, "finish_reason": "content_filter"}}}, ai.ErrSafetyBlocked},
+		{"no choices", map[string]interface{}{"choices": []interface{}{}}, ai.ErrEmptyResponse},
+		{"empty message", map[string]interface{}{"choices": []map[string]interface{}{{
+			"message": map[string]string{"content": " "}, "finish_reason": "stop"}}}, ai.ErrEmptyResponse},
+		{"not json", map[string]interface{}{"choices": []map[string]interface{}{{
+			"message": map[string]string{"content": "exposure +1"}, "finish_reason": "stop"}}}, ai.ErrMalformedResponse},
+		{"quota", map[string]interface{}{"error": map[string]string{"type": "insufficient_quota", "message": "out of credit"}}, ai.ErrQuota},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer srv.Close()
+
+			client, _ := ai.NewOpenAIClient("test-key", srv.URL, "test-model")
+			_, err := client.AnalyzeImageLR(context.Background(), []byte("img"), models.Metadata{}, ai.AnalysisOptions{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sidelight/internal/style"
+	"sidelight/internal/xmp"
+	"sidelight/pkg/models"
+)
+
+const systemInstruction = `You are a professional photo color grader. 
+Analyze the provided image and provide Adobe Camera Raw color grading parameters in JSON format.
+The parameters should aim for a natural, high-quality look unless a specific style is requested.
+Output ONLY the JSON object.`
+
+const pp3SystemInstruction = `You are an expert photo color grader for RawTherapee. 
+Analyze the image and output professional color grading parameters in JSON format.
+
+Recommended ranges for the key parameters:
+- compensation: (0.35 to 0.60) controls brightness.
+- contrast: (0 to 30)
+- saturation: (-100 to 20)
+- black: (0 to 100)
+- highlight_compr: (0 to 100)
+- temperature: (2000 to 10000)
+- tint: (0.8 to 1.2)
+- lab_brightness, lab_contrast, lab_chromaticity: (-20 to 20)
+- dehaze_strength: (0 to 30) for transparency and clarity.
+- sharpenmicro_strength: (0 to 40) for local contrast/clarity.
+- nr_luminance, nr_chrominance: (0 to 40) for noise reduction.
+
+Output ONLY the JSON object.`
+
+// lrPrompt builds the text prompt for Adobe Camera Raw grading.
+func lrPrompt(metadata models.Metadata, opts AnalysisOptions) string {
+	def := opts.StyleDefinition()
+	styleInstruction := def.Hint("lr") + styleBounds(def.Bounds.LR)
+
+	sections := ""
+	if opts.Refine != nil && opts.Refine.LR != nil {
+		sections = refinementSection(opts.Refine.Feedback, opts.Refine.LR)
+	}
+	if opts.Series != nil && opts.Series.LR != nil {
+		sections = seriesSection(opts.Series.LR) + sections
+	}
+	sections = contactSheetSection(opts.SeriesSheet) + sections + localSection(opts.SeriesSheet) + cropSection(opts.Crop) + describeSection(opts.Describe)
+	if def.Monochrome {
+		sections = lrMonochromeSection + sections
+	}
+	sections = profileSection(metadata.Make, def.Profile) + sections
+
+	metadataInfo := metadataSection(metadata)
+
+	return fmt.Sprintf(`%s
+
+Schema:
+%s
+%s
+    
+Current Style Goal: %s
+
+User Specific Instructions: %s
+%s%s
+Output ONLY the JSON object.`, systemInstruction, lrSchema.describe(), metadataInfo, styleInstruction, opts.UserPrompt, referenceSection(len(opts.ReferenceImages)), sections)
+}
+
+// pp3Prompt builds the text prompt for native RawTherapee grading.
+func pp3Prompt(metadata models.Metadata, opts AnalysisOptions) string {
+	// Use RT-specific hints instead of generic Adobe ones
+	def := opts.StyleDefinition()
+	styleInstruction := def.Hint("pp3") + styleBounds(def.Bounds.PP3)
+
+	metadataInfo := metadataSection(metadata)
+
+	sections := ""
+	if opts.Refine != nil && opts.Refine.PP3 != nil {
+		sections = refinementSection(opts.Refine.Feedback, opts.Refine.PP3)
+	}
+	if opts.Series != nil && opts.Series.PP3 != nil {
+		sections = seriesSection(opts.Series.PP3) + sections
+	}
+	sections = contactSheetSection(opts.SeriesSheet) + sections + localSection(opts.SeriesSheet) + cropSection(opts.Crop) + describeSection(opts.Describe)
+	if def.Monochrome {
+		sections = pp3MonochromeSection + sections
+	}
+
+	// Build user instruction section
+	userInstructions := ""
+	if opts.UserPrompt != "" {
+		userInstructions = fmt.Sprintf("\n\nUser Goal: %s", opts.UserPrompt)
+	}
+
+	return fmt.Sprintf(`%s
+
+Schema:
+%s
+%s
+    
+Desired Style: %s
+%s
+%s%s
+Analyze the image and generate the JSON for RawTherapee parameters.`,
+		pp3SystemInstruction, pp3Schema.describe(), metadataInfo, styleInstruction, userInstructions,
+		referenceSection(len(opts.ReferenceImages)), sections)
+}
+
+// metadataSection renders the EXIF context shared by all prompts. Tags the
+// file does not carry are left out rather than shown empty.
+func metadataSection(m models.Metadata) string {
+	var sb strings.Builder
+	sb.WriteString("Image Metadata:")
+	line := func(label, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(&sb, "\n- %s: %s", label, value)
+		}
+	}
+
+	line("Camera", m.Make+" "+m.Model)
+	line("Lens", m.Lens)
+	line("Focal Length", m.FocalLength)
+	if m.ISO > 0 {
+		line("ISO", strconv.Itoa(m.ISO))
+	}
+	line("Aperture", m.Aperture)
+	line("Shutter Speed", m.ShutterSpeed)
+	line("Exposure Compensation", m.ExposureCompensation)
+	line("Metering Mode", m.MeteringMode)
+	if m.FlashFired != nil {
+		line("Flash", map[bool]string{true: "fired", false: "did not fire"}[*m.FlashFired])
+	}
+	line("White Balance Setting", m.WhiteBalance)
+	if m.ColorTemperature > 0 {
+		line("Color Temperature As Shot", fmt.Sprintf("%dK", m.ColorTemperature))
+	}
+	line("Picture Style", m.PictureStyle)
+	line("Local Time", m.DateTime+" "+m.TimeZone)
+	if m.GPS != nil {
+		line("GPS", fmt.Sprintf("%.4f, %.4f", m.GPS.Latitude, m.GPS.Longitude))
+	}
+
+	sb.WriteString("\nUse these to judge the light: flash, high ISO noise, time of day and location all call for different grading.")
+	return sb.String()
+}
+
+// styleBounds renders the style's parameter limits for the prompt.
+func styleBounds(bounds map[string]style.Bound) string {
+	if len(bounds) == 0 {
+		return ""
+	}
+	return "\nThis style requires:\n" + strings.TrimSuffix(style.Describe(bounds), "\n")
+}
+
+// refinementSection asks the model to adjust a previous grade rather than start over.
+func refinementSection(feedback string, previous interface{}) string {
+	prev, _ := json.Marshal(previous)
+	return fmt.Sprintf(`
+Refinement: this image was already graded with the parameters below. Use them as the
+starting point and apply the feedback. Change only what the feedback calls for, keep
+every other value as it is, and return the complete updated parameter set.
+
+Previous Parameters: %s
+
+Feedback: %s
+`, prev, feedback)
+}
+
+// referenceSection explains the extra images sent after the photo.
+func referenceSection(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+Reference Images: the first image is the photo to grade. The %d image(s) after it are
+style references. Choose parameters that make the photo match the references' tonal
+range, contrast, white balance and color palette, taking the photo's own exposure and
+lighting into account. The references take precedence over the style goal.
+`, n)
+}
+
+// contactSheetSection asks for one base look for a series shown as a grid.
+func contactSheetSection(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+Series Contact Sheet: the image is a grid of %d photos from one series, such as an
+event or a shoot. Do not grade a single tile. Choose one base look (white balance,
+color palette, contrast and overall brightness) that suits the series as a whole;
+each photo will later be fine-tuned from it.
+`, n)
+}
+
+// seriesSection asks for a grade relative to the base look of the series.
+func seriesSection(base interface{}) string {
+	b, _ := json.Marshal(base)
+	return fmt.Sprintf(`
+Series: this photo belongs to a series graded with the shared base look below. Start
+from the base parameters so the photos match. Keep white balance, color and style
+parameters as in the base unless this photo's light clearly differs, and adjust
+exposure, highlights, shadows, whites and blacks only as far as needed to match the
+brightness of the series. Return the complete parameter set.
+
+Base Parameters: %s
+`, b)
+}
+
+// lrMonochromeSection and pp3MonochromeSection explain the channel mixer to
+// black and white styles.
+const lrMonochromeSection = `
+Black and White: set "convert_to_grayscale" to true and use the gray_mixer_* values
+to decide how bright each original color renders in gray, as a photographer would
+with color filters: e.g. darken blue for a dramatic sky, lift orange and red for
+clean skin, lift green for bright foliage. Toning, if any, goes in the split
+toning fields.
+`
+
+const pp3MonochromeSection = `
+Black and White: set "bw_enabled" to true and use the bw_mixer_* values (default 33
+each) to weight how much each original color contributes to the gray value, as a
+photographer would with color filters: e.g. lower blue for a dramatic sky, raise
+orange and red for clean skin. Toning, if any, goes in the ct_* fields.
+`
+
+// profileSection lists the camera profiles available for the camera. A
+// style that sets its own profile leaves no choice.
+func profileSection(cameraMake, fixed string) string {
+	if fixed != "" {
+		return fmt.Sprintf(`
+Camera Profile: this style starts from the %q profile; leave "camera_profile" empty
+and grade on top of it.
+`, fixed)
+	}
+	return fmt.Sprintf(`
+Camera Profile: for raw files, "camera_profile" may pick the base rendering the
+grade starts from: %s. Camera profiles reproduce the look of the camera's own
+picture styles. Leave it empty for the default.
+`, strings.Join(xmp.ProfileNames(cameraMake), ", "))
+}
+
+// localSection explains the optional masked corrections. A contact sheet
+// has no single geometry to mask, so it gets none.
+func localSection(sheetPhotos int) string {
+	if sheetPhotos > 0 {
+		return ""
+	}
+	return `
+Local Adjustments: when a region needs a correction the global sliders cannot
+make, add up to 4 entries to "local_adjustments": "linear" for a graduated filter
+(e.g. darken a bright sky from the top edge), "radial" for an elliptical area
+(e.g. lift a face), or "sky" / "subject" for masks the editor detects itself
+(no coordinates needed). Coordinates are fractions of the image from the top
+left corner. Keep the corrections subtle and leave the list empty when the
+global grade is enough.
+`
+}
+
+// cropSection asks for the optional crop field.
+func cropSection(crop bool) string {
+	if !crop {
+		return ""
+	}
+	return `
+Crop and Straighten: also fill in the "crop" field. Set "angle" to level a tilted
+horizon or straighten verticals (0 when already level); judge it from lines that
+should be horizontal or vertical, not from the subject. Give the crop rectangle as
+fractions of the upright image, tightening the composition only where it clearly
+helps, and keep it unchanged (0, 0, 1, 1) otherwise. Set "aspect" only when a
+specific ratio suits the photo.
+`
+}
+
+// describeSection asks for the optional scene field.
+func describeSection(describe bool) string {
+	if !describe {
+		return ""
+	}
+	return `
+Scene Description: also fill in the "scene" field with a suggested title, a one
+sentence caption, the scene type and subject keywords. Describe only what is
+visible; these are used as searchable catalog metadata.
+`
+}
@@ -0,0 +1,38 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"sidelight/pkg/models"
+)
+
+func TestMetadataSection_SharedByPrompts(t *testing.T) {
+	fired := true
+	meta := models.Metadata{
+		Make: "Canon", Model: "EOS R6", Lens: "RF35mm F1.8", FocalLength: "35.0 mm", ISO: 6400,
+		DateTime: "2024:12:24 21:30:00", TimeZone: "+01:00", FlashFired: &fired,
+		WhiteBalance: "Auto", ColorTemperature: 3100, PictureStyle: "Portrait",
+		GPS: &models.GPSPosition{Latitude: 48.2082, Longitude: 16.3738},
+	}
+	want := []string{
+		"- Lens: RF35mm F1.8", "- Focal Length: 35.0 mm", "- ISO: 6400", "- Flash: fired",
+		"- Color Temperature As Shot: 3100K", "- Picture Style: Portrait",
+		"- Local Time: 2024:12:24 21:30:00 +01:00", "- GPS: 48.2082, 16.3738",
+	}
+
+	for name, prompt := range map[string]string{
+		"lr":  lrPrompt(meta, AnalysisOptions{}),
+		"pp3": pp3Prompt(meta, AnalysisOptions{}),
+	} {
+		for _, w := range want {
+			if !strings.Contains(prompt, w) {
+				t.Errorf("%s prompt should contain %q", name, w)
+			}
+		}
+	}
+
+	if section := metadataSection(models.Metadata{Make: "Sony"}); strings.Contains(section, "- Lens") || strings.Contains(section, "- ISO") {
+		t.Errorf("tags missing from the file should be left out, got:\n%s", section)
+	}
+}
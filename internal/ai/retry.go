@@ -0,0 +1,303 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/googleapi"
+)
+
+// StatusError is returned by the HTTP-based clients for non-200 responses.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration // parsed from the Retry-After header, if present
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s request failed with status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Is reports whether the status matches an error kind such as ErrQuota.
+func (e *StatusError) Is(target error) bool {
+	kind := statusKind(e.StatusCode)
+	return kind != nil && kind == target
+}
+
+// outOfCredit reports whether a 429 means the account has run out of credit
+// (OpenAI's insufficient_quota) rather than hit a rate limit; waiting does
+// not help then.
+func (e *StatusError) outOfCredit() bool {
+	return e.StatusCode == http.StatusTooManyRequests && strings.Contains(e.Body, "insufficient_quota")
+}
+
+// newStatusError builds a StatusError from an HTTP response and its body.
+func newStatusError(provider string, resp *http.Response, body string) *StatusError {
+	return &StatusError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Body:       body,
+	}
+}
+
+// TransientError marks a failure that may succeed when retried, such as a
+// rate limit, an overloaded backend or an unusable model reply.
+type TransientError struct {
+	Err        error
+	RetryAfter time.Duration // server-provided hint, zero if unknown
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// PermanentError marks a failure that will not go away by retrying,
+// such as invalid credentials or a rejected request.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// transient marks err as retryable.
+func transient(err error) error {
+	return &TransientError{Err: err}
+}
+
+// classify wraps err in a TransientError or PermanentError. Errors that are
+// already classified are returned unchanged.
+func classify(err error) error {
+	var te *TransientError
+	var pe *PermanentError
+	if err == nil || errors.As(err, &te) || errors.As(err, &pe) {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &PermanentError{Err: err}
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		if retryableStatus(se.StatusCode) && !se.outOfCredit() {
+			return &TransientError{Err: err, RetryAfter: se.RetryAfter}
+		}
+		return &PermanentError{Err: err}
+	}
+
+	if ae, ok := apierror.FromError(err); ok {
+		if !retryableStatus(ae.HTTPCode()) {
+			return &PermanentError{Err: err}
+		}
+		var retryAfter time.Duration
+		if info := ae.Details().RetryInfo; info != nil && info.GetRetryDelay() != nil {
+			retryAfter = info.GetRetryDelay().AsDuration()
+		}
+		var gerr *googleapi.Error
+		if retryAfter == 0 && errors.As(err, &gerr) {
+			retryAfter = parseRetryAfter(gerr.Header.Get("Retry-After"))
+		}
+		return &TransientError{Err: err, RetryAfter: retryAfter}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return &TransientError{Err: err}
+	}
+
+	return &PermanentError{Err: err}
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: seconds and an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// RetryConfig controls retries and rate limiting of AI calls.
+type RetryConfig struct {
+	MaxAttempts       int           // total attempts per call, including the first
+	BaseDelay         time.Duration // delay before the first retry, doubled on each attempt
+	MaxDelay          time.Duration // upper bound for the computed backoff
+	RequestsPerMinute int           // global request budget, 0 disables limiting
+}
+
+// DefaultRetryConfig returns the settings used when none are configured.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 4,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    time.Minute,
+	}
+}
+
+// RetryClient wraps a Client with exponential backoff, Retry-After handling
+// and a requests-per-minute limiter shared by every goroutine using it.
+type RetryClient struct {
+	inner   Client
+	cfg     RetryConfig
+	limiter *rate.Limiter
+
+	mu         sync.Mutex
+	pauseUntil time.Time // set when the backend asks everyone to back off
+}
+
+// NewRetryClient wraps inner with the retry policy in cfg.
+func NewRetryClient(inner Client, cfg RetryConfig) *RetryClient {
+	defaults := DefaultRetryConfig()
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaults.MaxAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaults.BaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaults.MaxDelay
+	}
+
+	c := &RetryClient{inner: inner, cfg: cfg}
+	if cfg.RequestsPerMinute > 0 {
+		c.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(cfg.RequestsPerMinute)), 1)
+	}
+	return c
+}
+
+func (c *RetryClient) Close() error {
+	if closer, ok := c.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *RetryClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.GradingParams, error) {
+	return withRetry(ctx, c, func() (*models.GradingParams, error) {
+		return c.inner.AnalyzeImageLR(ctx, imageData, metadata, opts)
+	})
+}
+
+func (c *RetryClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts AnalysisOptions) (*models.PP3Params, error) {
+	return withRetry(ctx, c, func() (*models.PP3Params, error) {
+		return c.inner.AnalyzeImageForPP3(ctx, imageData, metadata, opts)
+	})
+}
+
+func (c *RetryClient) ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error) {
+	classifier, err := styleClassifier(c.inner)
+	if err != nil {
+		return nil, err
+	}
+	return withRetry(ctx, c, func() (*models.StyleChoice, error) {
+		return classifier.ClassifyStyle(ctx, imageData, metadata, candidates)
+	})
+}
+
+// withRetry runs call until it succeeds, fails permanently or runs out of
+// attempts. Permanent errors are returned as they are.
+func withRetry[T any](ctx context.Context, c *RetryClient, call func() (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+
+	attempt := 1
+	for ; attempt <= c.cfg.MaxAttempts; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return zero, &PermanentError{Err: err}
+		}
+
+		result, err := call()
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = classify(err)
+		var te *TransientError
+		if !errors.As(lastErr, &te) {
+			return zero, lastErr
+		}
+		if attempt == c.cfg.MaxAttempts {
+			break
+		}
+
+		delay := c.backoff(attempt)
+		if te.RetryAfter > 0 {
+			// The server knows best; hold back every worker, not just this one.
+			delay = te.RetryAfter
+			c.pause(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return zero, &PermanentError{Err: ctx.Err()}
+		case <-time.After(delay):
+		}
+	}
+
+	return zero, fmt.Errorf("giving up after %d attempts: %w", attempt, lastErr)
+}
+
+// backoff returns the exponential delay for the given attempt with full jitter.
+func (c *RetryClient) backoff(attempt int) time.Duration {
+	d := c.cfg.BaseDelay << (attempt - 1)
+	if d <= 0 || d > c.cfg.MaxDelay {
+		d = c.cfg.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// pause blocks new requests from all workers for d.
+func (c *RetryClient) pause(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until := time.Now().Add(d); until.After(c.pauseUntil) {
+		c.pauseUntil = until
+	}
+}
+
+// wait blocks until a shared pause has elapsed and the rate limiter admits a request.
+func (c *RetryClient) wait(ctx context.Context) error {
+	c.mu.Lock()
+	delay := time.Until(c.pauseUntil)
+	c.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	if c.limiter != nil {
+		return c.limiter.Wait(ctx)
+	}
+	return nil
+}
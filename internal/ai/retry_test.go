@@ -0,0 +1,146 @@
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+// flakyClient fails with err for the first failures calls, then succeeds.
+type flakyClient struct {
+	failures int32
+	err      error
+	calls    atomic.Int32
+}
+
+func (f *flakyClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+	if f.calls.Add(1) <= f.failures {
+		return nil, f.err
+	}
+	return &models.GradingParams{Exposure2012: 0.5}, nil
+}
+
+func (f *flakyClient) AnalyzeImageForPP3(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (*models.PP3Params, error) {
+	if f.calls.Add(1) <= f.failures {
+		return nil, f.err
+	}
+	return &models.PP3Params{Compensation: 0.5}, nil
+}
+
+var errBoom = fmt.Errorf("boom")
+
+var fastRetry = ai.RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryClient_RetriesTransientErrors(t *testing.T) {
+	inner := &flakyClient{failures: 2, err: &ai.StatusError{Provider: "test", StatusCode: http.StatusTooManyRequests}}
+	client := ai.NewRetryClient(inner, fastRetry)
+
+	params, err := client.AnalyzeImageLR(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if params.Exposure2012 != 0.5 || inner.calls.Load() != 3 {
+		t.Errorf("unexpected result %+v after %d calls", params, inner.calls.Load())
+	}
+}
+
+func TestRetryClient_GivesUp(t *testing.T) {
+	inner := &flakyClient{failures: 10, err: &ai.StatusError{Provider: "test", StatusCode: http.StatusServiceUnavailable}}
+	client := ai.NewRetryClient(inner, fastRetry)
+
+	_, err := client.AnalyzeImageForPP3(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{})
+	var te *ai.TransientError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected a TransientError, got %v", err)
+	}
+	if inner.calls.Load() != 3 || !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Errorf("expected 3 attempts, got %d (%v)", inner.calls.Load(), err)
+	}
+
+	limited := &flakyClient{failures: 10, err: &ai.StatusError{Provider: "test", StatusCode: http.StatusTooManyRequests}}
+	_, err = ai.NewRetryClient(limited, fastRetry).AnalyzeImageLR(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{})
+	if !errors.Is(err, ai.ErrQuota) {
+		t.Errorf("the error kind should survive retrying, got %v", err)
+	}
+}
+
+func TestRetryClient_DoesNotRetryPermanentErrors(t *testing.T) {
+	inner := &flakyClient{failures: 10, err: &ai.StatusError{Provider: "test", StatusCode: http.StatusUnauthorized}}
+	client := ai.NewRetryClient(inner, fastRetry)
+
+	_, err := client.AnalyzeImageLR(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{})
+	var pe *ai.PermanentError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PermanentError, got %v", err)
+	}
+	if inner.calls.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", inner.calls.Load())
+	}
+	if strings.Contains(err.Error(), "giving up") {
+		t.Errorf("permanent errors should be returned as they are, got %v", err)
+	}
+
+	billing := &flakyClient{failures: 10, err: &ai.StatusError{Provider: "test", StatusCode: http.StatusTooManyRequests,
+		Body: `{"error": {"type": "insufficient_quota", "message": "out of credit"}}`}}
+	_, err = ai.NewRetryClient(billing, fastRetry).AnalyzeImageLR(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{})
+	if !errors.Is(err, ai.ErrQuota) || !errors.As(err, &pe) || billing.calls.Load() != 1 {
+		t.Errorf("running out of credit should fail at once with ErrQuota, got %v after %d calls", err, billing.calls.Load())
+	}
+
+	unknown := &flakyClient{failures: 10, err: errBoom}
+	if _, err := ai.NewRetryClient(unknown, fastRetry).AnalyzeImageLR(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{}); !errors.As(err, &pe) {
+		t.Errorf("unclassified errors should be permanent, got %v", err)
+	}
+}
+
+func TestRetryClient_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "still failing", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	inner, _ := ai.NewOpenAIClient("key", srv.URL, "test-model")
+	client := ai.NewRetryClient(inner, fastRetry)
+
+	start := time.Now()
+	_, err := client.AnalyzeImageLR(context.Background(), []byte("img"), models.Metadata{}, ai.AnalysisOptions{})
+	if err == nil {
+		t.Fatal("expected the second (400) response to fail")
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After was not honored, retried after %v", elapsed)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls, got %d", calls.Load())
+	}
+}
+
+func TestRetryClient_RateLimit(t *testing.T) {
+	inner := &flakyClient{}
+	client := ai.NewRetryClient(inner, ai.RetryConfig{RequestsPerMinute: 1200}) // one request every 50ms
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := client.AnalyzeImageLR(context.Background(), nil, models.Metadata{}, ai.AnalysisOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected the limiter to space requests, 3 calls took %v", elapsed)
+	}
+}
@@ -0,0 +1,257 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"sidelight/pkg/models"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// responseSchema is the JSON Schema subset used to describe, request and
+// validate model output. It is generated from the struct tags of the target
+// type, so the prompt, the provider-native schema and the parser never drift apart.
+type responseSchema struct {
+	Type        string                     `json:"type"`
+	Description string                     `json:"description,omitempty"`
+	Properties  map[string]*responseSchema `json:"properties,omitempty"`
+	Required    []string                   `json:"required,omitempty"`
+	Items       *responseSchema            `json:"items,omitempty"`
+	Minimum     *float64                   `json:"minimum,omitempty"`
+	Maximum     *float64                   `json:"maximum,omitempty"`
+
+	order []string // property declaration order, for prompts
+}
+
+var (
+	lrSchema  = schemaFor(reflect.TypeOf(models.GradingParams{}))
+	pp3Schema = schemaFor(reflect.TypeOf(models.PP3Params{}))
+)
+
+// schemaFor builds the schema of a struct type from its field specs.
+func schemaFor(t reflect.Type) *responseSchema {
+	s := &responseSchema{Type: "object", Properties: map[string]*responseSchema{}}
+	for _, spec := range models.Fields(t) {
+		s.Properties[spec.Name] = fieldSchema(spec.Type, spec)
+		s.order = append(s.order, spec.Name)
+		if !spec.Optional {
+			s.Required = append(s.Required, spec.Name)
+		}
+	}
+	return s
+}
+
+// fieldSchema maps a Go type to a schema node. Ranges apply to numeric leaves,
+// so a [][]float64 curve with min/max constrains every coordinate.
+func fieldSchema(t reflect.Type, spec models.FieldSpec) *responseSchema {
+	var s *responseSchema
+	switch t.Kind() {
+	case reflect.Ptr:
+		return fieldSchema(t.Elem(), spec)
+	case reflect.Struct:
+		s = schemaFor(t)
+	case reflect.Slice, reflect.Array:
+		s = &responseSchema{Type: "array", Items: fieldSchema(t.Elem(), models.FieldSpec{Min: spec.Min, Max: spec.Max, HasRange: spec.HasRange})}
+	case reflect.Bool:
+		s = &responseSchema{Type: "boolean"}
+	case reflect.String:
+		s = &responseSchema{Type: "string"}
+	case reflect.Float32, reflect.Float64:
+		s = &responseSchema{Type: "number"}
+	default:
+		s = &responseSchema{Type: "integer"}
+	}
+
+	if spec.HasRange && (s.Type == "number" || s.Type == "integer") {
+		min, max := spec.Min, spec.Max
+		s.Minimum, s.Maximum = &min, &max
+	}
+	s.Description = spec.Desc
+	return s
+}
+
+// rangeText renders the numeric range of a leaf schema, e.g. "range -5 to 5".
+func (s *responseSchema) rangeText() string {
+	if s.Minimum == nil || s.Maximum == nil {
+		return ""
+	}
+	return fmt.Sprintf("range %g to %g", *s.Minimum, *s.Maximum)
+}
+
+// describe renders the schema as the bullet list embedded in prompts. The
+// fields of nested objects, and of objects in arrays, are listed indented
+// below their parent.
+func (s *responseSchema) describe() string {
+	var sb strings.Builder
+	for _, name := range s.order {
+		prop := s.Properties[name]
+		typ := prop.Type
+		nested := prop
+		if prop.Type == "array" {
+			typ = "array of " + prop.Items.Type
+			if prop.Items.Type == "array" {
+				typ += " of " + prop.Items.Items.Type
+			}
+			nested = prop.Items
+		}
+
+		var notes []string
+		if r := prop.rangeText(); r != "" {
+			notes = append(notes, r)
+		}
+		if prop.Description != "" {
+			notes = append(notes, prop.Description)
+		}
+		if !s.isRequired(name) {
+			notes = append(notes, "optional")
+		}
+
+		sb.WriteString(fmt.Sprintf("- %s: %s", name, typ))
+		if len(notes) > 0 {
+			sb.WriteString(" (" + strings.Join(notes, ", ") + ")")
+		}
+		sb.WriteString("\n")
+		if nested.Type == "object" && len(nested.order) > 0 {
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(nested.describe(), "\n"), "\n") {
+				sb.WriteString("  " + line)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func (s *responseSchema) isRequired(name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// genai converts the schema to Gemini's native structured-output schema.
+// Gemini has no numeric bounds, so ranges travel in the description.
+func (s *responseSchema) genai() *genai.Schema {
+	g := &genai.Schema{Description: s.Description, Required: s.Required}
+	if r := s.rangeText(); r != "" {
+		g.Description = strings.TrimPrefix(g.Description+"; "+r, "; ")
+	}
+
+	switch s.Type {
+	case "object":
+		g.Type = genai.TypeObject
+		g.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			g.Properties[name] = prop.genai()
+		}
+	case "array":
+		g.Type = genai.TypeArray
+		g.Items = s.Items.genai()
+	case "boolean":
+		g.Type = genai.TypeBoolean
+	case "string":
+		g.Type = genai.TypeString
+	case "number":
+		g.Type = genai.TypeNumber
+	default:
+		g.Type = genai.TypeInteger
+	}
+	return g
+}
+
+// validate checks the types and required fields of a decoded JSON value and
+// returns every violation found.
+func (s *responseSchema) validate(v interface{}, path string) []string {
+	var problems []string
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object", displayPath(path))}
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required field", joinPath(path, name)))
+			}
+		}
+		for _, name := range s.order {
+			if value, ok := obj[name]; ok && value != nil {
+				problems = append(problems, s.Properties[name].validate(value, joinPath(path, name))...)
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array", displayPath(path))}
+		}
+		for i, item := range arr {
+			problems = append(problems, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected boolean", displayPath(path)))
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected string", displayPath(path)))
+		}
+	default:
+		n, ok := v.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected %s", displayPath(path), s.Type)}
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			problems = append(problems, fmt.Sprintf("%s: expected integer, got %g", displayPath(path), n))
+		}
+		// Ranges are not checked here: out-of-range values are left to
+		// xmp.Sanitize and the writers, which clamp or reject them by policy
+		// instead of retrying the model.
+	}
+	return problems
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "response"
+	}
+	return path
+}
+
+// decodeResponse extracts the JSON object from a model reply, validates it
+// against schema and decodes it into v. Models occasionally wrap the object in
+// markdown fences or prose, so everything outside the outermost braces is discarded.
+// Failures are transient: another sample from the model usually parses.
+func decodeResponse(text string, schema *responseSchema, v interface{}) error {
+	cleanJSON := strings.TrimSpace(text)
+	if idx := strings.Index(cleanJSON, "{"); idx != -1 {
+		cleanJSON = cleanJSON[idx:]
+	}
+	if idx := strings.LastIndex(cleanJSON, "}"); idx != -1 {
+		cleanJSON = cleanJSON[:idx+1]
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal([]byte(cleanJSON), &raw); err != nil {
+		return transient(fmt.Errorf("%w: failed to parse AI response: %w (raw: %s)", ErrMalformedResponse, err, cleanJSON))
+	}
+	if problems := schema.validate(raw, ""); len(problems) > 0 {
+		return transient(fmt.Errorf("%w: AI response does not match schema: %s (raw: %s)", ErrMalformedResponse, strings.Join(problems, "; "), cleanJSON))
+	}
+
+	if err := json.Unmarshal([]byte(cleanJSON), v); err != nil {
+		return transient(fmt.Errorf("%w: failed to parse AI response: %w (raw: %s)", ErrMalformedResponse, err, cleanJSON))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sidelight/pkg/models"
+)
+
+func TestLRSchema_CoversEveryField(t *testing.T) {
+	typ := reflect.TypeOf(models.GradingParams{})
+	if got, want := len(lrSchema.Properties), typ.NumField(); got != want {
+		t.Fatalf("schema has %d properties, struct has %d fields", got, want)
+	}
+
+	exposure := lrSchema.Properties["exposure"]
+	if exposure.Type != "number" || *exposure.Minimum != -5 || *exposure.Maximum != 5 {
+		t.Errorf("unexpected exposure schema: %+v", exposure)
+	}
+	if lrSchema.Properties["contrast"].Type != "integer" {
+		t.Error("contrast should be an integer")
+	}
+	if !strings.Contains(lrSchema.describe(), "- vignette_amount: integer (range -100 to 0, negative values darken corners)") {
+		t.Errorf("prompt description is missing the vignette line:\n%s", lrSchema.describe())
+	}
+}
+
+func TestPP3Schema_OptionalCurves(t *testing.T) {
+	if pp3Schema.isRequired("tone_curve") {
+		t.Error("tone_curve should be optional")
+	}
+	if !pp3Schema.isRequired("compensation") {
+		t.Error("compensation should be required")
+	}
+	curve := pp3Schema.Properties["tone_curve"]
+	if curve.Type != "array" || curve.Items.Type != "array" || curve.Items.Items.Type != "number" {
+		t.Errorf("unexpected tone_curve schema: %+v", curve)
+	}
+
+	g := pp3Schema.genai()
+	if g.Properties["temperature"].Description != "range 1500 to 60000" {
+		t.Errorf("genai schema should carry the range in the description, got %q", g.Properties["temperature"].Description)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	valid := `{"compensation": 0.5, "contrast": 10, "saturation": 0, "black": 0, "highlight_compr": 0,
+		"shadow_recovery": 0, "highlight_recovery": 0, "temperature": 5500, "tint": 1.0,
+		"lab_brightness": 0, "lab_contrast": 0, "lab_chromaticity": 0, "sharpenmicro_strength": 0,
+		"sharpenmicro_contrast": 0, "sharpenmicro_uniformity": 0, "dehaze_strength": 0, "vib_pastels": 0,
+		"vib_saturated": 0, "nr_luminance": 0, "nr_chrominance": 0, "ct_shadow_r": 0, "ct_shadow_g": 0,
+		"ct_shadow_b": 0, "ct_highlight_r": 0, "ct_highlight_g": 0, "ct_highlight_b": 0, "ct_balance": 50,
+		"vignette_amount": 0, "tone_curve": [[0, 0], [0.5, 0.55], [1, 1]]}`
+
+	var params models.PP3Params
+	if err := decodeResponse("```json\n"+valid+"\n```", pp3Schema, &params); err != nil {
+		t.Fatalf("valid response rejected: %v", err)
+	}
+	if params.Temperature != 5500 || len(params.ToneCurve) != 3 {
+		t.Errorf("unexpected params: %+v", params)
+	}
+
+	// Ranges are enforced after decoding, so an out-of-range value is no reason to retry.
+	params = models.PP3Params{}
+	if err := decodeResponse(strings.Replace(valid, `"temperature": 5500`, `"temperature": 90000`, 1), pp3Schema, &params); err != nil || params.Temperature != 90000 {
+		t.Errorf("out-of-range value should decode, got %d, %v", params.Temperature, err)
+	}
+
+	tests := []struct {
+		name, body, want string
+	}{
+		{"missing field", strings.Replace(valid, `"contrast": 10, `, "", 1), "contrast: missing required field"},
+		{"not an integer", strings.Replace(valid, `"contrast": 10`, `"contrast": 10.5`, 1), "contrast: expected integer"},
+		{"wrong type", strings.Replace(valid, `"contrast": 10`, `"contrast": "high"`, 1), "contrast: expected integer"},
+		{"not json", "I cannot grade this image.", "failed to parse AI response"},
+	}
+	for _, tt := range tests {
+		err := decodeResponse(tt.body, pp3Schema, &models.PP3Params{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestSchema_Scene(t *testing.T) {
+	scene := lrSchema.Properties["scene"]
+	if lrSchema.isRequired("scene") || scene.Type != "object" {
+		t.Fatalf("scene should be an optional object, got %+v", scene)
+	}
+	if kw := scene.Properties["keywords"]; kw.Type != "array" || kw.Items.Type != "string" {
+		t.Errorf("unexpected keywords schema: %+v", kw)
+	}
+
+	reply := `{"exposure": 0, "contrast": 0, "highlights": 0, "shadows": 0, "whites": 0, "blacks": 0,
+		"texture": 0, "clarity": 0, "dehaze": 0, "vibrance": 0, "saturation": 0, "temperature": 5500, "tint": 0,
+		"sharpness": 0, "luminance_noise_reduction": 0, "color_noise_reduction": 0, "vignette_amount": 0,
+		"hue_red": 0, "hue_orange": 0, "hue_yellow": 0, "hue_green": 0, "hue_aqua": 0, "hue_blue": 0, "hue_purple": 0, "hue_magenta": 0,
+		"saturation_red": 0, "saturation_orange": 0, "saturation_yellow": 0, "saturation_green": 0, "saturation_aqua": 0, "saturation_blue": 0, "saturation_purple": 0, "saturation_magenta": 0,
+		"luminance_red": 0, "luminance_orange": 0, "luminance_yellow": 0, "luminance_green": 0, "luminance_aqua": 0, "luminance_blue": 0, "luminance_purple": 0, "luminance_magenta": 0,
+		"split_shadow_hue": 0, "split_shadow_saturation": 0, "split_highlight_hue": 0, "split_highlight_saturation": 0, "split_balance": 0,
+		"scene": {"title": "Harbor", "caption": "Boats.", "scene_type": "landscape", "keywords": ["boats", "harbor"]}}`
+	var params models.GradingParams
+	if err := decodeResponse(reply, lrSchema, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Scene == nil || params.Scene.Title != "Harbor" || len(params.Scene.Keywords) != 2 {
+		t.Errorf("unexpected scene %+v", params.Scene)
+	}
+}
+
+func TestSchema_LocalAdjustments(t *testing.T) {
+	local := lrSchema.Properties["local_adjustments"]
+	if lrSchema.isRequired("local_adjustments") || local.Type != "array" || local.Items.Type != "object" {
+		t.Fatalf("local_adjustments should be an optional array of objects, got %+v", local)
+	}
+	if !local.Items.isRequired("exposure") || local.Items.isRequired("x1") {
+		t.Error("exposure should be required and coordinates optional")
+	}
+	if desc := pp3Schema.describe(); !strings.Contains(desc, "  - exposure: number (range -4 to 4, stops)") {
+		t.Errorf("nested fields should be listed indented under their parent:\n%s", desc)
+	}
+
+	problems := local.validate([]interface{}{map[string]interface{}{"type": "radial", "exposure": "bright", "contrast": 0.0, "temperature": 0.0}}, "local_adjustments")
+	if len(problems) != 1 || !strings.Contains(problems[0], "local_adjustments[0].exposure") {
+		t.Errorf("expected one invalid exposure, got %v", problems)
+	}
+}
@@ -0,0 +1,164 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"math"
+	"strings"
+	"sync"
+
+	"sidelight/pkg/models"
+)
+
+// UsageTracker accumulates the token usage of all AI calls made with a
+// context returned by TrackUsage. It is safe for concurrent use.
+type UsageTracker struct {
+	mu     sync.Mutex
+	usage  models.Usage
+	parent *UsageTracker
+}
+
+type usageKey struct{}
+
+// TrackUsage returns a context whose AI calls are recorded in the returned
+// tracker. Trackers nest: calls also count towards trackers of ctx, so a
+// per-file tracker and a per-run tracker can be used together.
+func TrackUsage(ctx context.Context) (context.Context, *UsageTracker) {
+	t := &UsageTracker{}
+	t.parent, _ = ctx.Value(usageKey{}).(*UsageTracker)
+	return context.WithValue(ctx, usageKey{}, t), t
+}
+
+// Total returns the usage recorded so far.
+func (t *UsageTracker) Total() models.Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// recordUsage adds one call to the tracker of ctx, if any. Clients call it
+// for every response they receive, including ones that fail to decode, since
+// those tokens are billed too.
+func recordUsage(ctx context.Context, u models.Usage) {
+	u.Calls = 1
+	t, _ := ctx.Value(usageKey{}).(*UsageTracker)
+	for ; t != nil; t = t.parent {
+		t.mu.Lock()
+		t.usage.Add(u)
+		t.mu.Unlock()
+	}
+}
+
+// splitInput divides the reported input tokens into text and image tokens,
+// given an estimate for the images.
+func splitInput(model string, input, output, imageEstimate int) models.Usage {
+	images := min(imageEstimate, input)
+	return models.Usage{
+		Model:        model,
+		PromptTokens: input - images,
+		ImageTokens:  images,
+		OutputTokens: output,
+	}
+}
+
+// geminiImageTokens estimates Gemini's image cost: 258 tokens for images up
+// to 384px on both sides, otherwise 258 per 768x768 tile.
+func geminiImageTokens(images [][]byte) int {
+	total := 0
+	for _, img := range images {
+		w, h := imageSize(img)
+		if w <= 384 && h <= 384 {
+			total += 258
+			continue
+		}
+		tiles := math.Ceil(float64(w)/768) * math.Ceil(float64(h)/768)
+		total += int(tiles) * 258
+	}
+	return total
+}
+
+// openAIImageTokens estimates OpenAI's high detail image cost: the image is
+// fit into 2048x2048, scaled so the short side is at most 768px, and charged
+// 170 tokens per 512px tile plus 85.
+func openAIImageTokens(images [][]byte) int {
+	total := 0
+	for _, img := range images {
+		w, h := imageSize(img)
+		fw, fh := float64(w), float64(h)
+		if scale := 2048 / math.Max(fw, fh); scale < 1 {
+			fw, fh = fw*scale, fh*scale
+		}
+		if scale := 768 / math.Min(fw, fh); scale < 1 {
+			fw, fh = fw*scale, fh*scale
+		}
+		tiles := math.Ceil(fw/512) * math.Ceil(fh/512)
+		total += 85 + 170*int(tiles)
+	}
+	return total
+}
+
+// imageSize returns the dimensions of an encoded image, or 0x0 if unknown.
+func imageSize(data []byte) (int, int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// Price is the cost of a model in USD per million tokens. Image tokens are
+// billed as input.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// PriceTable maps model names to prices. A model matches the longest entry
+// that is a prefix of its name, so "gemini-2.5-flash" also covers dated
+// variants such as "gemini-2.5-flash-preview-05-20".
+type PriceTable map[string]Price
+
+// DefaultPrices returns list prices of common models at the time of writing.
+// They can be overridden or extended in the configuration file.
+func DefaultPrices() PriceTable {
+	return PriceTable{
+		"gemini-2.5-pro":        {Input: 1.25, Output: 10},
+		"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+		"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+		"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+		"gpt-4o":                {Input: 2.50, Output: 10},
+		"gpt-4o-mini":           {Input: 0.15, Output: 0.60},
+		"gpt-4.1":               {Input: 2, Output: 8},
+		"gpt-4.1-mini":          {Input: 0.40, Output: 1.60},
+	}
+}
+
+// Lookup returns the price of model. An empty entry matches every model.
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	var best string
+	found := false
+	for name := range t {
+		if strings.HasPrefix(model, name) && (!found || len(name) > len(best)) {
+			best, found = name, true
+		}
+	}
+	if !found {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost returns the estimated cost of u in USD. ok is false if the model has
+// no price. Usage without calls, such as cache hits, costs nothing.
+func (t PriceTable) Cost(u models.Usage) (cost float64, ok bool) {
+	if u.Calls == 0 {
+		return 0, true
+	}
+	p, ok := t.Lookup(u.Model)
+	if !ok {
+		return 0, false
+	}
+	input := float64(u.PromptTokens + u.ImageTokens)
+	return (input*p.Input + float64(u.OutputTokens)*p.Output) / 1e6, true
+}
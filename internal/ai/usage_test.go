@@ -0,0 +1,76 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+func TestTrackUsage_OpenAI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{
+				"message": map[string]string{"role": "assistant", "content": mustJSON(t, models.GradingParams{Temperature: 5500})},
+			}},
+			"usage": map[string]int{"prompt_tokens": 1500, "completion_tokens": 300},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := ai.NewOpenAIClient("test-key", srv.URL, "gpt-4o-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, run := ai.TrackUsage(context.Background())
+	fileCtx, file := ai.TrackUsage(runCtx)
+	img := solidJPEG(t, color.RGBA{R: 100, G: 100, B: 100})
+	for i := 0; i < 2; i++ {
+		if _, err := client.AnalyzeImageLR(fileCtx, img, models.Metadata{}, ai.AnalysisOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.AnalyzeImageLR(runCtx, img, models.Metadata{}, ai.AnalysisOptions{})
+
+	got := file.Total()
+	// A 64x48 image is a single tile: 85 + 170 tokens.
+	want := models.Usage{Model: "gpt-4o-mini", Calls: 2, PromptTokens: 2 * 1245, ImageTokens: 2 * 255, OutputTokens: 600}
+	if got != want {
+		t.Errorf("file usage = %+v, want %+v", got, want)
+	}
+	if run.Total().Calls != 3 {
+		t.Errorf("the run tracker should see all 3 calls, got %d", run.Total().Calls)
+	}
+}
+
+func TestPriceTable(t *testing.T) {
+	prices := ai.DefaultPrices()
+
+	p, ok := prices.Lookup("gemini-2.5-flash-lite-preview")
+	if !ok || p != prices["gemini-2.5-flash-lite"] {
+		t.Errorf("expected the longest matching prefix, got %+v", p)
+	}
+	if _, ok := prices.Lookup("llava"); ok {
+		t.Error("unknown models should have no price")
+	}
+	free := ai.PriceTable{"": {}}
+	if p, ok := free.Lookup("llava"); !ok || p != (ai.Price{}) {
+		t.Errorf("an empty prefix should match every model, got %+v (%v)", p, ok)
+	}
+
+	usage := models.Usage{Model: "gpt-4o", Calls: 1, PromptTokens: 600_000, ImageTokens: 400_000, OutputTokens: 100_000}
+	cost, ok := prices.Cost(usage)
+	if !ok || math.Abs(cost-3.5) > 1e-9 {
+		t.Errorf("expected $3.50, got %v (%v)", cost, ok)
+	}
+	if cost, ok := prices.Cost(models.Usage{Model: "llava"}); !ok || cost != 0 {
+		t.Error("usage without calls should be free")
+	}
+}
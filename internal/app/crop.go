@@ -0,0 +1,129 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg"
+	"math"
+	"strconv"
+	"strings"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+// minCropSize is the smallest crop side, as a fraction of the image, that is
+// taken as a real suggestion rather than a misplaced rectangle.
+const minCropSize = 0.2
+
+// fitCrop prepares the crop suggestion of a grade for the writers. It is
+// dropped unless opts.Crop asked for one or when it changes nothing. An
+// unusable rectangle keeps the full frame, so the straightening still
+// applies. The rectangle is then shrunk around its centre to the requested
+// aspect ratio and the full-resolution pixel size is recorded.
+func fitCrop(c *models.CropSuggestion, opts ai.AnalysisOptions, metadata *models.Metadata, previewData []byte) *models.CropSuggestion {
+	if c == nil || !opts.Crop {
+		return nil
+	}
+	out := *c
+	out.Left, out.Right = math.Min(c.Left, c.Right), math.Max(c.Left, c.Right)
+	out.Top, out.Bottom = math.Min(c.Top, c.Bottom), math.Max(c.Top, c.Bottom)
+	if out.Right-out.Left < minCropSize || out.Bottom-out.Top < minCropSize {
+		out.Top, out.Left, out.Bottom, out.Right = 0, 0, 1, 1
+	}
+
+	width, height := uprightSize(metadata)
+	out.Width, out.Height = width, height
+	if width == 0 || height == 0 {
+		// The preview is upright too, so it still gives the aspect ratio.
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(previewData)); err == nil {
+			width, height = cfg.Width, cfg.Height
+		}
+	}
+	if ratio, ok := parseAspect(out.Aspect, width, height); ok && width > 0 && height > 0 {
+		fitAspect(&out, ratio, float64(width), float64(height))
+	}
+
+	if out.Angle == 0 && out.Top == 0 && out.Left == 0 && out.Bottom == 1 && out.Right == 1 {
+		return nil
+	}
+	return &out
+}
+
+// uprightSize returns the pixel size of the image as displayed, or zeros if
+// the metadata does not say.
+func uprightSize(m *models.Metadata) (int, int) {
+	if m.Orientation >= 5 && m.Orientation <= 8 {
+		return m.ImageHeight, m.ImageWidth
+	}
+	return m.ImageWidth, m.ImageHeight
+}
+
+// parseAspect reads an aspect ratio such as "4:5", "16x9" or "original" as
+// width over height.
+func parseAspect(aspect string, width, height int) (float64, bool) {
+	aspect = strings.ToLower(strings.TrimSpace(aspect))
+	if aspect == "original" || aspect == "as shot" {
+		if width == 0 || height == 0 {
+			return 0, false
+		}
+		return float64(width) / float64(height), true
+	}
+	w, h, ok := strings.Cut(strings.ReplaceAll(aspect, "x", ":"), ":")
+	if !ok {
+		return 0, false
+	}
+	wf, err1 := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	hf, err2 := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if err1 != nil || err2 != nil || wf <= 0 || hf <= 0 {
+		return 0, false
+	}
+	return wf / hf, true
+}
+
+// fitAspect shrinks the crop around its centre until its pixel aspect ratio
+// is ratio.
+func fitAspect(c *models.CropSuggestion, ratio, width, height float64) {
+	w, h := (c.Right-c.Left)*width, (c.Bottom-c.Top)*height
+	if w/h > ratio {
+		cx, half := (c.Left+c.Right)/2, h*ratio/width/2
+		c.Left, c.Right = cx-half, cx+half
+	} else {
+		cy, half := (c.Top+c.Bottom)/2, w/ratio/height/2
+		c.Top, c.Bottom = cy-half, cy+half
+	}
+}
+
+// storedCrop converts a crop of the upright image to the frame the file is
+// stored in, which is what Lightroom's crs:Crop* values refer to. Mirrored
+// orientations also reverse the direction of the straightening angle.
+func storedCrop(c *models.CropSuggestion, orientation int) (top, left, bottom, right, angle float64) {
+	toStored := func(u, v float64) (float64, float64) {
+		switch orientation {
+		case 2:
+			return 1 - u, v
+		case 3:
+			return 1 - u, 1 - v
+		case 4:
+			return u, 1 - v
+		case 5:
+			return v, u
+		case 6:
+			return v, 1 - u
+		case 7:
+			return 1 - v, 1 - u
+		case 8:
+			return 1 - v, u
+		default:
+			return u, v
+		}
+	}
+	x1, y1 := toStored(c.Left, c.Top)
+	x2, y2 := toStored(c.Right, c.Bottom)
+
+	angle = c.Angle
+	if orientation == 2 || orientation == 4 || orientation == 5 || orientation == 7 {
+		angle = -angle
+	}
+	return math.Min(y1, y2), math.Min(x1, x2), math.Max(y1, y2), math.Max(x1, x2), angle
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"math"
+	"testing"
+
+	"sidelight/internal/ai"
+	"sidelight/pkg/models"
+)
+
+func TestFitCrop(t *testing.T) {
+	meta := &models.Metadata{ImageWidth: 6000, ImageHeight: 4000, Orientation: 6}
+	suggestion := &models.CropSuggestion{Top: 0.1, Left: 0, Bottom: 0.9, Right: 1, Angle: 1.5, Aspect: "1:1"}
+
+	if fitCrop(suggestion, ai.AnalysisOptions{}, meta, nil) != nil {
+		t.Error("a crop must be dropped unless it was asked for")
+	}
+
+	crop := fitCrop(suggestion, ai.AnalysisOptions{Crop: true}, meta, nil)
+	if crop == nil {
+		t.Fatal("expected a crop")
+	}
+	if crop.Width != 4000 || crop.Height != 6000 {
+		t.Errorf("expected the upright size 4000x6000, got %dx%d", crop.Width, crop.Height)
+	}
+	// Full width of a portrait frame is 4000px; a square keeps it and trims the height.
+	if crop.Left != 0 || crop.Right != 1 || math.Abs((crop.Bottom-crop.Top)*6000-4000) > 1e-6 || math.Abs(crop.Top+crop.Bottom-1) > 1e-9 {
+		t.Errorf("expected a centred square, got %+v", crop)
+	}
+
+	tiny := &models.CropSuggestion{Top: 0.5, Left: 0.5, Bottom: 0.55, Right: 0.55, Angle: -2}
+	if crop := fitCrop(tiny, ai.AnalysisOptions{Crop: true}, meta, nil); crop == nil || crop.Bottom != 1 || crop.Angle != -2 {
+		t.Errorf("an unusable rectangle should fall back to the full frame and keep the angle, got %+v", crop)
+	}
+	if fitCrop(&models.CropSuggestion{Bottom: 1, Right: 1}, ai.AnalysisOptions{Crop: true}, meta, nil) != nil {
+		t.Error("a crop that changes nothing should be dropped")
+	}
+}
+
+func TestStoredCrop(t *testing.T) {
+	crop := &models.CropSuggestion{Top: 0.1, Left: 0.2, Bottom: 0.7, Right: 0.9, Angle: 2}
+
+	top, left, bottom, right, angle := storedCrop(crop, 1)
+	if top != 0.1 || left != 0.2 || bottom != 0.7 || right != 0.9 || angle != 2 {
+		t.Errorf("upright files need no conversion, got %v %v %v %v %v", top, left, bottom, right, angle)
+	}
+	// Rotated 90° clockwise for display: the upright top edge is the stored left edge.
+	top, left, bottom, right, _ = storedCrop(crop, 6)
+	if math.Abs(top-0.1) > 1e-9 || math.Abs(left-0.1) > 1e-9 || math.Abs(bottom-0.8) > 1e-9 || math.Abs(right-0.7) > 1e-9 {
+		t.Errorf("unexpected stored crop %v %v %v %v", top, left, bottom, right)
+	}
+	if _, _, _, _, angle = storedCrop(crop, 2); angle != -2 {
+		t.Errorf("mirrored files reverse the angle, got %v", angle)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,7 +10,9 @@ import (
 
 	"sidelight/internal/ai"
 	"sidelight/internal/extractor"
+	"sidelight/internal/preview"
 	"sidelight/internal/rt"
+	"sidelight/internal/style"
 	"sidelight/internal/xmp"
 	"sidelight/pkg/models"
 )
@@ -18,16 +21,56 @@ import (
 type Processor struct {
 	extractor extractor.Extractor
 	aiClient  ai.Client
-	Formats   []string // e.g., ["xmp", "pp3"]
+	Formats   []string            // e.g., ["xmp", "pp3"]
+	Preview   preview.Options     // how previews are normalized before upload
+	Styles    *style.Registry     // catalog that style names and --style auto resolve against
+	Sanitize  xmp.SanitizeOptions // range checks applied to LR parameters before the XMP is written
 }
 
+// classifyPreview is the preview size for style selection; telling a
+// portrait from a landscape needs far less detail than grading.
+var classifyPreview = preview.Options{LongEdge: 512, Quality: 80}
+
 // NewProcessor creates a new Processor.
 func NewProcessor(ext extractor.Extractor, ai ai.Client) *Processor {
 	return &Processor{
 		extractor: ext,
 		aiClient:  ai,
 		Formats:   []string{"xmp"},
+		Preview:   preview.DefaultOptions(),
+		Styles:    style.Builtin(),
+		Sanitize:  xmp.DefaultSanitizeOptions(),
+	}
+}
+
+// LoadReference extracts and normalizes the preview of a reference image, in
+// the same form ProcessFile sends photos, for use in AnalysisOptions.ReferenceImages.
+func (p *Processor) LoadReference(ctx context.Context, path string) ([]byte, error) {
+	data, _, err := p.loadPreview(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("reference %s: %w", filepath.Base(path), err)
+	}
+	return data, nil
+}
+
+// loadPreview extracts the preview and metadata of a file and normalizes the
+// preview: upright, downscaled JPEG regardless of what the file embeds.
+func (p *Processor) loadPreview(ctx context.Context, path string) ([]byte, *models.Metadata, error) {
+	previewData, err := p.extractor.ExtractPreview(ctx, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("extraction failed: %w", err)
+	}
+
+	metadata, err := p.extractor.ExtractMetadata(ctx, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("metadata extraction failed: %w", err)
 	}
+
+	previewData, err = preview.Normalize(previewData, metadata.Orientation, p.Preview)
+	if err != nil {
+		return nil, nil, fmt.Errorf("preview normalization failed: %w", err)
+	}
+	return previewData, metadata, nil
 }
 
 // ProcessFile handles a single photo file (RAW or standard).
@@ -35,19 +78,20 @@ func (p *Processor) ProcessFile(ctx context.Context, rawPath string, opts ai.Ana
 	result := &models.ProcessingResult{
 		SourcePath: rawPath,
 	}
+	ctx, usage := ai.TrackUsage(ctx)
+	defer func() { result.Usage = usage.Total() }()
 
-	// 1. Extract Preview
-	previewData, err := p.extractor.ExtractPreview(ctx, rawPath)
+	// 1. Extract preview and metadata
+	previewData, metadata, err := p.loadPreview(ctx, rawPath)
 	if err != nil {
-		return nil, fmt.Errorf("extraction failed: %w", err)
+		return nil, err
 	}
+	result.Metadata = *metadata
 
-	// 1.5 Extract Metadata
-	metadata, err := p.extractor.ExtractMetadata(ctx, rawPath)
-	if err != nil {
-		return nil, fmt.Errorf("metadata extraction failed: %w", err)
+	if opts, result.AutoStyle, err = p.resolveStyle(ctx, previewData, *metadata, opts); err != nil {
+		return nil, err
 	}
-	result.Metadata = *metadata
+	result.Style = opts.Definition.Name
 
 	// 2. Generate sidecars based on requested formats independently
 	// Deduplicate formats to avoid redundant processing
@@ -56,12 +100,39 @@ func (p *Processor) ProcessFile(ctx context.Context, rawPath string, opts ai.Ana
 		uniqueFormats[strings.ToLower(f)] = true
 	}
 
+	// Refinement starts from the grade already written next to the file
+	if opts.Refine != nil {
+		if opts.Refine, err = loadRefinement(rawPath, opts.Refine, uniqueFormats); err != nil {
+			return nil, err
+		}
+	}
+
 	// Handle XMP (Adobe)
 	if uniqueFormats["xmp"] {
-		params, err := p.aiClient.AnalyzeImageLR(ctx, previewData, *metadata, opts)
+		params, spread, err := ai.Consensus(ctx, opts, func(ctx context.Context, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+			return p.aiClient.AnalyzeImageLR(ctx, previewData, *metadata, opts)
+		})
 		if err != nil {
 			return nil, fmt.Errorf("ai analysis (LR) failed: %w", err)
 		}
+		result.Spread = append(result.Spread, withFormat("xmp", spread)...)
+		style.Apply(opts.StyleDefinition().Bounds.LR, params)
+		if opts.StyleDefinition().Monochrome {
+			params.ConvertToGrayscale = true
+		}
+		if profile := opts.StyleDefinition().Profile; profile != "" {
+			params.CameraProfile = profile
+		}
+		sanitize := p.Sanitize
+		sanitize.AsShotTemperature = metadata.ColorTemperature
+		fixes, err := xmp.Sanitize(params, sanitize)
+		if err != nil {
+			return nil, fmt.Errorf("LR parameters rejected: %w", err)
+		}
+		for _, v := range fixes {
+			result.Sanitized = append(result.Sanitized, v.String())
+		}
+		params.Crop = fitCrop(params.Crop, opts, metadata, previewData)
 		result.Params = *params
 
 		if err := p.generateXMP(ctx, rawPath, params, result); err != nil {
@@ -79,6 +150,52 @@ func (p *Processor) ProcessFile(ctx context.Context, rawPath string, opts ai.Ana
 	return result, nil
 }
 
+// resolveStyle settles opts on a registered style. For style.Auto the style
+// of the series, or else the one picked for the image, is used. Unknown names
+// are an error rather than a silent fallback to the default.
+func (p *Processor) resolveStyle(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (ai.AnalysisOptions, *models.StyleChoice, error) {
+	var choice *models.StyleChoice
+	if strings.EqualFold(opts.Style, style.Auto) {
+		if opts.Series != nil && opts.Series.Style != nil {
+			choice = opts.Series.Style
+		} else {
+			var err error
+			if choice, err = p.classifyStyle(ctx, imageData, metadata); err != nil {
+				return opts, nil, err
+			}
+		}
+		opts.Style, opts.Definition = choice.Style, nil
+	}
+
+	if opts.Definition == nil {
+		def, err := p.Styles.Get(opts.Style)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.Definition = def
+	}
+	return opts, choice, nil
+}
+
+// classifyStyle picks the style for an image from the catalog in a cheap
+// first pass on a small copy of the preview.
+func (p *Processor) classifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata) (*models.StyleChoice, error) {
+	classifier, ok := p.aiClient.(ai.StyleClassifier)
+	if !ok {
+		return nil, fmt.Errorf("AI client does not support automatic style selection")
+	}
+
+	small, err := preview.Normalize(imageData, 0, classifyPreview)
+	if err != nil {
+		return nil, fmt.Errorf("preview normalization failed: %w", err)
+	}
+	choice, err := classifier.ClassifyStyle(ctx, small, metadata, p.Styles.List())
+	if err != nil {
+		return nil, fmt.Errorf("style selection failed: %w", err)
+	}
+	return choice, nil
+}
+
 func (p *Processor) generateXMP(ctx context.Context, rawPath string, params *models.GradingParams, result *models.ProcessingResult) error {
 	settings := xmp.NewCameraRawSettings()
 
@@ -88,6 +205,8 @@ func (p *Processor) generateXMP(ctx context.Context, rawPath string, params *mod
 		params.Temperature = 0
 		params.Tint = 0
 		settings.CameraProfile = "Embedded"
+	} else if profile, look, ok := xmp.ResolveProfile(result.Metadata.Make, params.CameraProfile); ok {
+		settings.CameraProfile, settings.Look = profile, look
 	}
 
 	// Mapping logic... (omitted for brevity, will include full content in actual tool call)
@@ -112,6 +231,21 @@ func (p *Processor) generateXMP(ctx context.Context, rawPath string, params *mod
 	settings.ColorNoiseReduction = params.ColorNoiseReduction
 
 	settings.PostCropVignetteAmount = params.PostCropVignetteAmount
+	settings.PostCropVignetteMidpoint = params.PostCropVignetteMidpoint
+	settings.PostCropVignetteFeather = params.PostCropVignetteFeather
+	settings.PostCropVignetteRoundness = params.PostCropVignetteRoundness
+	settings.PostCropVignetteStyle = params.PostCropVignetteStyle
+	settings.PostCropVignetteHighlights = params.PostCropVignetteHighlights
+
+	settings.GrainAmount = params.GrainAmount
+	settings.GrainSize = params.GrainSize
+	settings.GrainFrequency = params.GrainFrequency
+
+	settings.LensProfileEnable = params.LensProfileEnable
+	if params.LensProfileEnable == 1 {
+		settings.LensProfileSetup = "LensDefaults"
+	}
+	settings.AutoLateralCA = params.AutoLateralCA
 
 	// HSL & Split Toning mapping
 	settings.HueAdjustmentRed = params.HueAdjustmentRed
@@ -141,21 +275,75 @@ func (p *Processor) generateXMP(ctx context.Context, rawPath string, params *mod
 	settings.LuminanceAdjustmentPurple = params.LuminanceAdjustmentPurple
 	settings.LuminanceAdjustmentMagenta = params.LuminanceAdjustmentMagenta
 
+	if params.ConvertToGrayscale {
+		settings.ConvertToGrayscale = "True"
+		settings.GrayMixerRed = params.GrayMixerRed
+		settings.GrayMixerOrange = params.GrayMixerOrange
+		settings.GrayMixerYellow = params.GrayMixerYellow
+		settings.GrayMixerGreen = params.GrayMixerGreen
+		settings.GrayMixerAqua = params.GrayMixerAqua
+		settings.GrayMixerBlue = params.GrayMixerBlue
+		settings.GrayMixerPurple = params.GrayMixerPurple
+		settings.GrayMixerMagenta = params.GrayMixerMagenta
+	}
+
 	settings.SplitToningShadowHue = params.SplitToningShadowHue
 	settings.SplitToningShadowSaturation = params.SplitToningShadowSaturation
 	settings.SplitToningHighlightHue = params.SplitToningHighlightHue
 	settings.SplitToningHighlightSaturation = params.SplitToningHighlightSaturation
 	settings.SplitToningBalance = params.SplitToningBalance
 
-	xmpData, err := xmp.Marshal(settings)
-	if err != nil {
-		return fmt.Errorf("xmp marshaling failed: %w", err)
+	settings.ColorGradeShadowLum = params.ColorGradeShadowLum
+	settings.ColorGradeMidtoneHue = params.ColorGradeMidtoneHue
+	settings.ColorGradeMidtoneSat = params.ColorGradeMidtoneSat
+	settings.ColorGradeMidtoneLum = params.ColorGradeMidtoneLum
+	settings.ColorGradeHighlightLum = params.ColorGradeHighlightLum
+	settings.ColorGradeGlobalHue = params.ColorGradeGlobalHue
+	settings.ColorGradeGlobalSat = params.ColorGradeGlobalSat
+	settings.ColorGradeGlobalLum = params.ColorGradeGlobalLum
+	settings.ColorGradeBlending = params.ColorGradeBlending
+
+	settings.ParametricShadows = params.ParametricShadows
+	settings.ParametricDarks = params.ParametricDarks
+	settings.ParametricLights = params.ParametricLights
+	settings.ParametricHighlights = params.ParametricHighlights
+
+	settings.ToneCurvePV2012 = params.ToneCurvePV2012
+	settings.ToneCurvePV2012Red = params.ToneCurvePV2012Red
+	settings.ToneCurvePV2012Green = params.ToneCurvePV2012Green
+	settings.ToneCurvePV2012Blue = params.ToneCurvePV2012Blue
+	if len(params.ToneCurvePV2012)+len(params.ToneCurvePV2012Red)+len(params.ToneCurvePV2012Green)+len(params.ToneCurvePV2012Blue) > 0 {
+		settings.ToneCurveName2012 = "Custom"
+	}
+
+	settings.Corrections = params.LocalAdjustments
+
+	if crop := params.Crop; crop != nil {
+		settings.HasCrop = "True"
+		settings.CropTop, settings.CropLeft, settings.CropBottom, settings.CropRight, settings.CropAngle = storedCrop(crop, result.Metadata.Orientation)
+		settings.CropConstrainToWarp = 1 // keep the rotated crop inside the image
+	}
+
+	var dc xmp.DublinCore
+	if scene := params.Scene; scene != nil {
+		dc = xmp.DublinCore{Title: scene.Title, Description: scene.Caption, Subject: scene.Subjects()}
 	}
 
 	ext := filepath.Ext(rawPath)
 	xmpPath := strings.TrimSuffix(rawPath, ext) + ".xmp"
 	result.XmpPath = xmpPath
 
+	// An existing sidecar is updated in place, keeping ratings, keywords
+	// and anything else other applications stored in it.
+	existing, err := os.ReadFile(xmpPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read existing xmp file: %w", err)
+	}
+	xmpData, err := xmp.Merge(existing, settings, dc)
+	if err != nil {
+		return fmt.Errorf("xmp marshaling failed: %w", err)
+	}
+
 	if err := os.WriteFile(xmpPath, xmpData, 0644); err != nil {
 		return fmt.Errorf("failed to write xmp file: %w", err)
 	}
@@ -194,10 +382,18 @@ func (p *Processor) generatePP3Native(ctx context.Context, rawPath string, previ
 	}
 
 	// Get PP3 native params from AI
-	pp3Params, err := nativeAnalyzer.AnalyzeImageForPP3(ctx, previewData, *metadata, opts)
+	pp3Params, spread, err := ai.Consensus(ctx, opts, func(ctx context.Context, opts ai.AnalysisOptions) (*models.PP3Params, error) {
+		return nativeAnalyzer.AnalyzeImageForPP3(ctx, previewData, *metadata, opts)
+	})
 	if err != nil {
 		return fmt.Errorf("PP3 native analysis failed: %w", err)
 	}
+	result.Spread = append(result.Spread, withFormat("pp3", spread)...)
+	style.Apply(opts.StyleDefinition().Bounds.PP3, pp3Params)
+	if opts.StyleDefinition().Monochrome {
+		pp3Params.BWEnabled = true
+	}
+	pp3Params.Crop = fitCrop(pp3Params.Crop, opts, metadata, previewData)
 	result.PP3Params = pp3Params
 
 	// Detect if file is RAW or standard image (JPG/PNG)
@@ -216,3 +412,11 @@ func (p *Processor) generatePP3Native(ctx context.Context, rawPath string, previ
 	}
 	return nil
 }
+
+// withFormat tags consensus spreads with the output format they belong to.
+func withFormat(format string, spread []models.ParamSpread) []models.ParamSpread {
+	for i := range spread {
+		spread[i].Format = format
+	}
+	return spread
+}
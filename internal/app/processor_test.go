@@ -1,13 +1,22 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"sidelight/internal/ai"
+	"sidelight/internal/style"
+	"sidelight/internal/xmp"
 	"sidelight/pkg/models"
 )
 
@@ -15,7 +24,9 @@ import (
 type MockExtractor struct{}
 
 func (m *MockExtractor) ExtractPreview(ctx context.Context, rawPath string) ([]byte, error) {
-	return []byte("fake-image-data"), nil
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	return buf.Bytes(), err
 }
 func (m *MockExtractor) ExtractMetadata(ctx context.Context, rawPath string) (*models.Metadata, error) {
 	return &models.Metadata{}, nil
@@ -90,3 +101,315 @@ sContent := string(xmpContent)
 		t.Error("XMP should contain crs:HasSettings=\"True\"")
 	}
 }
+
+// recordingAIClient remembers the options of the last call.
+type recordingAIClient struct {
+	MockAIClient
+	opts ai.AnalysisOptions
+}
+
+func (m *recordingAIClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+	m.opts = opts
+	return m.MockAIClient.AnalyzeImageLR(ctx, imageData, metadata, opts)
+}
+
+func TestProcessFile_Refine(t *testing.T) {
+	client := &recordingAIClient{}
+	proc := NewProcessor(&MockExtractor{}, client)
+	ctx := context.Background()
+
+	tmpDir := t.TempDir()
+	rawPath := filepath.Join(tmpDir, "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ai.AnalysisOptions{Refine: &ai.Refinement{Feedback: "warmer"}}
+	if _, err := proc.ProcessFile(ctx, rawPath, opts); err == nil {
+		t.Fatal("refining without an existing sidecar should fail")
+	}
+
+	// First grade writes test.xmp; the refinement must start from it.
+	if _, err := proc.ProcessFile(ctx, rawPath, ai.AnalysisOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proc.ProcessFile(ctx, rawPath, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	refine := client.opts.Refine
+	if refine == nil || refine.LR == nil || refine.Feedback != "warmer" {
+		t.Fatalf("expected the previous grade to be passed along, got %+v", refine)
+	}
+	if refine.LR.Exposure2012 != 1.0 || refine.LR.Temperature != 5000 {
+		t.Errorf("unexpected previous params %+v", refine.LR)
+	}
+	if opts.Refine.LR != nil {
+		t.Error("the caller's options must not be modified")
+	}
+}
+
+func TestGradingParamsFromXMP_Profile(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.CameraProfile, settings.Look = "Camera CLASSIC CHROME", "Vintage 07"
+	if got := gradingParamsFromXMP(settings).CameraProfile; got != "Camera CLASSIC CHROME" {
+		t.Errorf("a creative look must keep the base profile, got %q", got)
+	}
+
+	settings.CameraProfile, settings.Look = "Adobe Standard", "Adobe Landscape"
+	if got := gradingParamsFromXMP(settings).CameraProfile; got != "Adobe Landscape" {
+		t.Errorf("an Adobe Raw look names the profile, got %q", got)
+	}
+}
+
+func TestAnalyzeSeries(t *testing.T) {
+	client := &recordingAIClient{}
+	proc := NewProcessor(&MockExtractor{}, client)
+
+	base, err := proc.AnalyzeSeries(context.Background(), []string{"a.ARW", "b.ARW", "c.ARW"}, ai.AnalysisOptions{Style: "natural"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.LR == nil || base.LR.Exposure2012 != 1.0 {
+		t.Fatalf("expected the LR base look, got %+v", base.LR)
+	}
+	if base.PP3 != nil {
+		t.Error("PP3 base should only be analyzed when the pp3 format is requested")
+	}
+	if client.opts.SeriesSheet != 3 || client.opts.Series != nil {
+		t.Errorf("the contact sheet analysis should be marked as such, got %+v", client.opts)
+	}
+}
+
+// sceneAIClient describes the image.
+type sceneAIClient struct{ MockAIClient }
+
+func (m *sceneAIClient) AnalyzeImageLR(ctx context.Context, imageData []byte, metadata models.Metadata, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+	params, _ := m.MockAIClient.AnalyzeImageLR(ctx, imageData, metadata, opts)
+	params.Scene = &models.SceneInfo{Title: "Old Town", Caption: "A narrow street.", Type: "street", Keywords: []string{"alley"}}
+	return params, nil
+}
+
+func TestProcessFile_SceneMetadata(t *testing.T) {
+	proc := NewProcessor(&MockExtractor{}, &sceneAIClient{})
+	rawPath := filepath.Join(t.TempDir(), "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Describe: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(res.XmpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{">Old Town<", ">A narrow street.<", "<rdf:li>alley</rdf:li>", "<rdf:li>street</rdf:li>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("XMP should contain %s", want)
+		}
+	}
+}
+
+// styleAIClient picks a fixed style and records the grading options.
+type styleAIClient struct {
+	recordingAIClient
+	classified int
+}
+
+func (m *styleAIClient) ClassifyStyle(ctx context.Context, imageData []byte, metadata models.Metadata, candidates []*style.Style) (*models.StyleChoice, error) {
+	m.classified++
+	return &models.StyleChoice{Scene: "food", Style: "food"}, nil
+}
+
+func TestProcessFile_AutoStyle(t *testing.T) {
+	client := &styleAIClient{}
+	proc := NewProcessor(&MockExtractor{}, client)
+	rawPath := filepath.Join(t.TempDir(), "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Style: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Style != "food" || res.AutoStyle == nil || res.AutoStyle.Scene != "food" {
+		t.Errorf("expected the picked style in the result, got %q %+v", res.Style, res.AutoStyle)
+	}
+	if def := client.opts.Definition; def == nil || def.Name != "food" {
+		t.Errorf("grading should use the picked style, got %+v", def)
+	}
+
+	// A series shares the style picked for its contact sheet.
+	base, err := proc.AnalyzeSeries(context.Background(), []string{"a.ARW", "b.ARW"}, ai.AnalysisOptions{Style: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Style == nil || base.Style.Style != "food" {
+		t.Fatalf("expected the series style, got %+v", base.Style)
+	}
+	client.classified = 0
+	if _, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Style: "auto", Series: base}); err != nil {
+		t.Fatal(err)
+	}
+	if client.classified != 0 {
+		t.Error("photos in a series should not be classified again")
+	}
+}
+
+func TestProcessFile_UnknownStyle(t *testing.T) {
+	proc := NewProcessor(&MockExtractor{}, &MockAIClient{})
+	rawPath := filepath.Join(t.TempDir(), "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Style: "no-such-style"}); !errors.Is(err, style.ErrUnknownStyle) {
+		t.Errorf("expected ErrUnknownStyle, got %v", err)
+	}
+	if _, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Style: "auto"}); err == nil {
+		t.Error("auto style needs a client that can classify")
+	}
+}
+
+func TestProcessFile_MonochromeStyle(t *testing.T) {
+	proc := NewProcessor(&MockExtractor{}, &MockAIClient{})
+	proc.Formats = []string{"xmp", "pp3"}
+	rawPath := filepath.Join(t.TempDir(), "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Style: "bw-contrast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Params.ConvertToGrayscale || res.PP3Params == nil || !res.PP3Params.BWEnabled {
+		t.Errorf("a black and white style should always convert to grayscale, got %+v %+v", res.Params, res.PP3Params)
+	}
+	data, err := os.ReadFile(res.XmpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `crs:ConvertToGrayscale="True"`) {
+		t.Error("XMP should convert to grayscale")
+	}
+	pp3, err := os.ReadFile(strings.TrimSuffix(rawPath, filepath.Ext(rawPath)) + ".pp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(pp3), "[Black & White]") {
+		t.Error("PP3 should enable the black and white module")
+	}
+}
+
+func TestProcessFile_StyleProfile(t *testing.T) {
+	proc := NewProcessor(&MockExtractor{}, &MockAIClient{})
+	rawPath := filepath.Join(t.TempDir(), "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{Style: "kodak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(res.XmpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The mock camera has no make, so camera-portrait falls back to Adobe Portrait.
+	for _, want := range []string{`crs:CameraProfile="Adobe Standard"`, `crs:Name="Adobe Portrait"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("XMP should contain %s", want)
+		}
+	}
+}
+
+func TestProcessFile_KeepsExistingSidecar(t *testing.T) {
+	proc := NewProcessor(&MockExtractor{}, &MockAIClient{})
+	dir := t.TempDir()
+	rawPath := filepath.Join(dir, "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	existing := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/" xmp:Rating="5" crs:Contrast2012="40"/>
+</rdf:RDF></x:xmpmeta>`
+	if err := os.WriteFile(filepath.Join(dir, "test.xmp"), []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(res.XmpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `xmp:Rating="5"`) || !strings.Contains(string(data), `crs:Exposure2012="1"`) {
+		t.Errorf("the grade should be merged into the existing sidecar, got:\n%s", data)
+	}
+	if strings.Contains(string(data), "Contrast2012") {
+		t.Error("the previous grade should be replaced")
+	}
+}
+
+func TestProcessFile_OutOfRangeReply(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		reply, _ := json.Marshal(models.GradingParams{Exposure2012: 7, Contrast2012: 20, Temperature: 5500})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{
+				"message":       map[string]string{"role": "assistant", "content": string(reply)},
+				"finish_reason": "stop",
+			}},
+		})
+	}))
+	defer srv.Close()
+	client, err := ai.NewOpenAIClient("test-key", srv.URL, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	rawPath := filepath.Join(dir, "test.ARW")
+	if err := os.WriteFile(rawPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Clamp: the reply is accepted as is and pulled to the documented range.
+	proc := NewProcessor(&MockExtractor{}, client)
+	proc.Sanitize = xmp.SanitizeOptions{Policy: xmp.PolicyClamp}
+	res, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("an out-of-range reply should not be retried, got %d calls", calls)
+	}
+	if res.Params.Exposure2012 != 5 || len(res.Sanitized) != 1 || !strings.Contains(res.Sanitized[0], "exposure 7") {
+		t.Errorf("expected exposure clamped to 5 and reported, got %g %v", res.Params.Exposure2012, res.Sanitized)
+	}
+	data, err := os.ReadFile(res.XmpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `crs:Exposure2012="5"`) {
+		t.Error("XMP should contain the clamped exposure")
+	}
+
+	// Reject: the file fails and no sidecar is written.
+	os.Remove(res.XmpPath)
+	proc.Sanitize = xmp.SanitizeOptions{Policy: xmp.PolicyReject}
+	if _, err := proc.ProcessFile(context.Background(), rawPath, ai.AnalysisOptions{}); !errors.Is(err, xmp.ErrOutOfRange) {
+		t.Errorf("expected ErrOutOfRange, got %v", err)
+	}
+	if _, err := os.Stat(res.XmpPath); !os.IsNotExist(err) {
+		t.Error("no XMP should be written for a rejected file")
+	}
+}
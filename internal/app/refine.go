@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"sidelight/internal/ai"
+	"sidelight/internal/rt"
+	"sidelight/internal/xmp"
+	"sidelight/pkg/models"
+)
+
+// loadRefinement completes a refinement request with the grade previously
+// written next to rawPath: the .xmp for the LR output and the .pp3 for the
+// RawTherapee output. Each requested format needs its sidecar.
+func loadRefinement(rawPath string, base *ai.Refinement, formats map[string]bool) (*ai.Refinement, error) {
+	refine := *base
+	stem := strings.TrimSuffix(rawPath, filepath.Ext(rawPath))
+
+	if formats["xmp"] && refine.LR == nil {
+		data, err := os.ReadFile(stem + ".xmp")
+		if err != nil {
+			return nil, fmt.Errorf("no previous grade to refine: %w", err)
+		}
+		settings, err := xmp.Unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read previous grade: %w", err)
+		}
+		refine.LR = gradingParamsFromXMP(settings)
+	}
+
+	if (formats["pp3"] || formats["rt"]) && refine.PP3 == nil {
+		data, err := os.ReadFile(stem + ".pp3")
+		if err != nil {
+			return nil, fmt.Errorf("no previous grade to refine: %w", err)
+		}
+		params, err := rt.ParsePP3(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read previous grade: %w", err)
+		}
+		refine.PP3 = params
+	}
+
+	return &refine, nil
+}
+
+// gradingParamsFromXMP is the inverse of the mapping in generateXMP. The
+// settings struct mirrors GradingParams field by field, so fields are copied by name.
+func gradingParamsFromXMP(settings xmp.CameraRawSettings) *models.GradingParams {
+	params := &models.GradingParams{}
+	src := reflect.ValueOf(settings)
+	dst := reflect.ValueOf(params).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		f := src.FieldByName(dst.Type().Field(i).Name)
+		if f.IsValid() && f.Type() == dst.Field(i).Type() {
+			dst.Field(i).Set(f)
+		}
+	}
+	params.ConvertToGrayscale = strings.EqualFold(settings.ConvertToGrayscale, "True")
+	params.CameraProfile = xmp.ProfileName(settings.CameraProfile, settings.Look)
+	return params
+}
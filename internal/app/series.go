@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sidelight/internal/ai"
+	"sidelight/internal/preview"
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+// AnalyzeSeries derives the shared base look of a series of photos from a
+// contact sheet of their previews, for each requested format. Pass the result
+// as AnalysisOptions.Series to ProcessFile to grade the photos relative to it.
+func (p *Processor) AnalyzeSeries(ctx context.Context, paths []string, opts ai.AnalysisOptions) (*ai.SeriesBase, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("series has no photos")
+	}
+
+	previews := make([][]byte, len(paths))
+	var metadata models.Metadata
+	for i, path := range paths {
+		data, meta, err := p.loadPreview(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("series preview %s: %w", path, err)
+		}
+		previews[i] = data
+		if i == 0 {
+			// Camera and lens describe the whole series; exposure settings do not.
+			metadata = models.Metadata{Make: meta.Make, Model: meta.Model, Lens: meta.Lens}
+		}
+	}
+
+	sheet, err := preview.ContactSheet(previews, p.Preview)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.SeriesSheet = len(paths)
+	opts.Series = nil
+	opts.Refine = nil
+	opts.Describe = false // a description of the sheet would describe no photo
+	opts.Crop = false     // nor would a crop of it
+
+	// With style.Auto the whole series gets the style picked for the sheet.
+	opts, choice, err := p.resolveStyle(ctx, sheet, metadata, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	formats := make(map[string]bool)
+	for _, f := range p.Formats {
+		formats[strings.ToLower(f)] = true
+	}
+
+	base := &ai.SeriesBase{Style: choice}
+	if formats["xmp"] {
+		params, _, err := ai.Consensus(ctx, opts, func(ctx context.Context, opts ai.AnalysisOptions) (*models.GradingParams, error) {
+			return p.aiClient.AnalyzeImageLR(ctx, sheet, metadata, opts)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("series analysis (LR) failed: %w", err)
+		}
+		style.Apply(opts.StyleDefinition().Bounds.LR, params)
+		params.LocalAdjustments = nil // masks belong to single photos
+		base.LR = params
+	}
+	if formats["pp3"] || formats["rt"] {
+		params, _, err := ai.Consensus(ctx, opts, func(ctx context.Context, opts ai.AnalysisOptions) (*models.PP3Params, error) {
+			return p.aiClient.AnalyzeImageForPP3(ctx, sheet, metadata, opts)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("series analysis (PP3) failed: %w", err)
+		}
+		style.Apply(opts.StyleDefinition().Bounds.PP3, params)
+		params.LocalAdjustments = nil // masks belong to single photos
+		base.PP3 = params
+	}
+	return base, nil
+}
@@ -81,16 +81,39 @@ func (e *ExifToolExtractor) ExtractPreview(ctx context.Context, rawPath string)
 }
 
 type exiftoolOutput struct {
-	Make             string      `json:"Make"`
-	Model            string      `json:"Model"`
-	Lens             string      `json:"Lens"`
-	LensID           string      `json:"LensID"`
-	LensModel        string      `json:"LensModel"`
-	ISO              interface{} `json:"ISO"` // Can be int or string depending on -n
-	Aperture         interface{} `json:"Aperture"`
-	ShutterSpeed     interface{} `json:"ShutterSpeed"`
-	FocalLength      interface{} `json:"FocalLength"`
-	DateTimeOriginal string      `json:"DateTimeOriginal"`
+	Make               string      `json:"Make"`
+	Model              string      `json:"Model"`
+	Lens               string      `json:"Lens"`
+	LensID             string      `json:"LensID"`
+	LensModel          string      `json:"LensModel"`
+	ISO                interface{} `json:"ISO"` // Can be int or string depending on -n
+	Aperture           interface{} `json:"Aperture"`
+	ShutterSpeed       interface{} `json:"ShutterSpeed"`
+	FocalLength        interface{} `json:"FocalLength"`
+	DateTimeOriginal   string      `json:"DateTimeOriginal"`
+	OffsetTimeOriginal string      `json:"OffsetTimeOriginal"`
+	Orientation        interface{} `json:"Orientation"`
+	ImageWidth         interface{} `json:"ImageWidth"`
+	ImageHeight        interface{} `json:"ImageHeight"`
+
+	ExposureCompensation interface{} `json:"ExposureCompensation"`
+	MeteringMode         string      `json:"MeteringMode"`
+	Flash                interface{} `json:"Flash"` // numeric, bit 0 is set when the flash fired
+	WhiteBalance         string      `json:"WhiteBalance"`
+	ColorTemperature     interface{} `json:"ColorTemperature"`
+	ColorTempAsShot      interface{} `json:"ColorTempAsShot"` // Canon
+
+	// Picture style under its maker-specific names
+	PictureStyle       string `json:"PictureStyle"`       // Canon
+	FilmMode           string `json:"FilmMode"`           // Fujifilm
+	CreativeStyle      string `json:"CreativeStyle"`      // Sony
+	PictureControlName string `json:"PictureControlName"` // Nikon
+	PictureMode        string `json:"PictureMode"`        // Olympus, Pentax
+
+	GPSLatitude     interface{} `json:"GPSLatitude"`
+	GPSLatitudeRef  string      `json:"GPSLatitudeRef"`
+	GPSLongitude    interface{} `json:"GPSLongitude"`
+	GPSLongitudeRef string      `json:"GPSLongitudeRef"`
 }
 
 // ExtractMetadata extracts technical details from the image file.
@@ -113,6 +136,25 @@ func (e *ExifToolExtractor) ExtractMetadata(ctx context.Context, rawPath string)
 		"-ShutterSpeed",
 		"-FocalLength",
 		"-DateTimeOriginal",
+		"-OffsetTimeOriginal",
+		"-Orientation#", // numeric (1-8), used to rotate previews that lack their own tag
+		"-ImageWidth#",
+		"-ImageHeight#",
+		"-ExposureCompensation",
+		"-MeteringMode",
+		"-Flash#",
+		"-WhiteBalance",
+		"-ColorTemperature#",
+		"-ColorTempAsShot#",
+		"-PictureStyle",
+		"-FilmMode",
+		"-CreativeStyle",
+		"-PictureControlName",
+		"-PictureMode",
+		"-GPSLatitude#",
+		"-GPSLatitudeRef#",
+		"-GPSLongitude#",
+		"-GPSLongitudeRef#",
 		rawPath,
 	}
 
@@ -146,37 +188,7 @@ func (e *ExifToolExtractor) ExtractMetadata(ctx context.Context, rawPath string)
 		lens = o.LensID
 	}
 
-	// Helper to stringify interface{} safely
-	toString := func(v interface{}) string {
-		if v == nil {
-			return ""
-		}
-		return fmt.Sprintf("%v", v)
-	}
-
-	// Helper to int safely
-	toInt := func(v interface{}) int {
-		if v == nil {
-			return 0
-		}
-		switch val := v.(type) {
-		case float64:
-			return int(val)
-		case int:
-			return val
-		case string:
-			// try parsing if strictly needed, but usually ISO is just a number in JSON if simple
-			// but exiftool without -n might give "100" or "Hi 100".
-			// For now, let's just fmt.Sscanf if it's a string, or 0.
-			var i int
-			fmt.Sscanf(val, "%d", &i)
-			return i
-		default:
-			return 0
-		}
-	}
-
-	return &models.Metadata{
+	meta := &models.Metadata{
 		Make:         o.Make,
 		Model:        o.Model,
 		Lens:         lens,
@@ -185,7 +197,90 @@ func (e *ExifToolExtractor) ExtractMetadata(ctx context.Context, rawPath string)
 		ShutterSpeed: toString(o.ShutterSpeed),
 		FocalLength:  toString(o.FocalLength),
 		DateTime:     o.DateTimeOriginal,
-	}, nil
+		TimeZone:     o.OffsetTimeOriginal,
+		Orientation:  toInt(o.Orientation),
+		ImageWidth:   toInt(o.ImageWidth),
+		ImageHeight:  toInt(o.ImageHeight),
+
+		ExposureCompensation: toString(o.ExposureCompensation),
+		MeteringMode:         o.MeteringMode,
+		WhiteBalance:         o.WhiteBalance,
+		ColorTemperature:     toInt(o.ColorTemperature),
+		PictureStyle:         firstNonEmpty(o.PictureStyle, o.FilmMode, o.CreativeStyle, o.PictureControlName, o.PictureMode),
+	}
+	if meta.ColorTemperature == 0 {
+		meta.ColorTemperature = toInt(o.ColorTempAsShot)
+	}
+	if o.Flash != nil {
+		fired := toInt(o.Flash)&1 == 1
+		meta.FlashFired = &fired
+	}
+	if o.GPSLatitude != nil && o.GPSLongitude != nil {
+		meta.GPS = &models.GPSPosition{
+			Latitude:  signedDegrees(toFloat(o.GPSLatitude), o.GPSLatitudeRef == "S"),
+			Longitude: signedDegrees(toFloat(o.GPSLongitude), o.GPSLongitudeRef == "W"),
+		}
+	}
+	return meta, nil
+}
+
+// toString stringifies an exiftool value safely.
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// toInt converts an exiftool value to int. Without -n, values such as ISO
+// may come as strings ("100" or "Hi 100"); the leading number is used.
+func toInt(v interface{}) int {
+	switch val := v.(type) {
+	case float64:
+		return int(val)
+	case int:
+		return val
+	case string:
+		var i int
+		fmt.Sscanf(val, "%d", &i)
+		return i
+	default:
+		return 0
+	}
+}
+
+// toFloat converts a numeric exiftool value to float64.
+func toFloat(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case string:
+		var f float64
+		fmt.Sscanf(val, "%g", &f)
+		return f
+	default:
+		return 0
+	}
+}
+
+// signedDegrees applies the hemisphere to a coordinate. exiftool reports the
+// composite coordinate signed and the raw EXIF one unsigned with a reference.
+func signedDegrees(deg float64, negative bool) float64 {
+	if negative && deg > 0 {
+		return -deg
+	}
+	return deg
+}
+
+// firstNonEmpty returns the first non-empty value. Cameras that do not use a
+// maker tag leave it unset, so at most one is normally present.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
 }
 
 // EmbedXMP embeds the XMP metadata from xmpPath into the image at imagePath.
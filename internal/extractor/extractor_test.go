@@ -3,6 +3,7 @@ package extractor_test
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"sidelight/internal/extractor"
@@ -86,4 +87,40 @@ func TestExifToolExtractor_ExtractPreview_JPG(t *testing.T) {
 	}
 
 	t.Logf("Successfully extracted %d bytes from %s", len(data), jpgPath)
-}
\ No newline at end of file
+}
+
+func TestExifToolExtractor_ExtractMetadata_Tags(t *testing.T) {
+	// A stand-in for exiftool that prints a canned JSON reply.
+	dir := t.TempDir()
+	script := filepath.Join(dir, "exiftool")
+	reply := `[{"Make": "FUJIFILM", "Model": "X-T5", "ISO": 6400, "DateTimeOriginal": "2024:12:24 19:05:00",
+		"OffsetTimeOriginal": "+01:00", "ExposureCompensation": "-2/3", "MeteringMode": "Multi-segment",
+		"Flash": 9, "WhiteBalance": "Auto", "ColorTemperature": 3200, "FilmMode": "Classic Chrome",
+		"GPSLatitude": 33.8688, "GPSLatitudeRef": "S", "GPSLongitude": 151.2093, "GPSLongitudeRef": "E",
+		"ImageWidth": 7728, "ImageHeight": 5152}]`
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat <<'EOF'\n"+reply+"\nEOF\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := &extractor.ExifToolExtractor{BinPath: script}
+	meta, err := ext.ExtractMetadata(context.Background(), "photo.RAF")
+	if err != nil {
+		t.Fatalf("Failed to extract metadata: %v", err)
+	}
+
+	if meta.ISO != 6400 || meta.TimeZone != "+01:00" || meta.ExposureCompensation != "-2/3" || meta.MeteringMode != "Multi-segment" {
+		t.Errorf("Unexpected exposure metadata: %+v", meta)
+	}
+	if meta.FlashFired == nil || !*meta.FlashFired {
+		t.Error("Flash value 9 means the flash fired")
+	}
+	if meta.WhiteBalance != "Auto" || meta.ColorTemperature != 3200 || meta.PictureStyle != "Classic Chrome" {
+		t.Errorf("Unexpected color metadata: %+v", meta)
+	}
+	if meta.ImageWidth != 7728 || meta.ImageHeight != 5152 {
+		t.Errorf("Unexpected image size %dx%d", meta.ImageWidth, meta.ImageHeight)
+	}
+	if meta.GPS == nil || meta.GPS.Latitude != -33.8688 || meta.GPS.Longitude != 151.2093 {
+		t.Errorf("Expected a southern hemisphere position, got %+v", meta.GPS)
+	}
+}
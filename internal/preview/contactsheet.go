@@ -0,0 +1,61 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// sheetGap is the spacing between contact sheet cells in pixels.
+const sheetGap = 4
+
+// ContactSheet tiles previews into a single JPEG no wider than opts.LongEdge,
+// in reading order. Each preview is center-cropped to a 3:2 cell, so the sheet
+// is nearly all image content and its overall statistics reflect the series.
+func ContactSheet(previews [][]byte, opts Options) ([]byte, error) {
+	if len(previews) == 0 {
+		return nil, fmt.Errorf("contact sheet needs at least one image")
+	}
+
+	cols := int(math.Ceil(math.Sqrt(float64(len(previews)))))
+	rows := (len(previews) + cols - 1) / cols
+
+	width := opts.LongEdge
+	if width <= 0 {
+		width = DefaultOptions().LongEdge
+	}
+	cellW := (width - sheetGap*(cols+1)) / cols
+	cellH := cellW * 2 / 3
+	if cellW < 1 || cellH < 1 {
+		return nil, fmt.Errorf("contact sheet of %d images does not fit in %dpx", len(previews), width)
+	}
+	height := rows*cellH + sheetGap*(rows+1)
+
+	sheet := imaging.New(width, height, color.Gray{Y: 32})
+	for i, data := range previews {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode preview %d: %w", i+1, err)
+		}
+		thumb := imaging.Fill(img, cellW, cellH, imaging.Center, imaging.Box)
+		x := sheetGap + (i%cols)*(cellW+sheetGap)
+		y := sheetGap + (i/cols)*(cellH+sheetGap)
+		sheet = imaging.Paste(sheet, thumb, image.Pt(x, y))
+	}
+
+	quality := opts.Quality
+	if quality <= 0 || quality > 100 {
+		quality = DefaultOptions().Quality
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode contact sheet: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,151 @@
+// Package preview normalizes extracted previews before they are sent to a model:
+// decode, apply the EXIF orientation, downscale and re-encode as JPEG.
+package preview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // Ensure PNG decoding is available
+
+	"github.com/disintegration/imaging"
+)
+
+// Options controls the normalized preview.
+type Options struct {
+	LongEdge int // maximum size of the long edge in pixels, 0 keeps the original size
+	Quality  int // JPEG quality (1-100)
+}
+
+// DefaultOptions returns the settings used when none are configured. 1536px
+// is enough for grading decisions while keeping uploads to a few hundred KB.
+func DefaultOptions() Options {
+	return Options{LongEdge: 1536, Quality: 85}
+}
+
+// Normalize decodes a JPEG or PNG preview, rotates it upright and returns it
+// as a JPEG no larger than opts.LongEdge. The orientation tag embedded in the
+// image wins; fallbackOrientation (the EXIF orientation of the source file,
+// 1-8) is used for previews without one, as is typical for RAW previews.
+func Normalize(data []byte, fallbackOrientation int, opts Options) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode preview: %w", err)
+	}
+
+	orientation := readOrientation(data)
+	if orientation == 0 {
+		orientation = fallbackOrientation
+	}
+	img = orient(img, orientation)
+
+	if b := img.Bounds(); opts.LongEdge > 0 && max(b.Dx(), b.Dy()) > opts.LongEdge {
+		img = imaging.Fit(img, opts.LongEdge, opts.LongEdge, imaging.Lanczos)
+	}
+
+	// JPEG has no alpha; flatten transparent PNGs onto white instead of black.
+	if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		bg := imaging.New(img.Bounds().Dx(), img.Bounds().Dy(), color.White)
+		img = imaging.Overlay(bg, img, image.Pt(0, 0), 1)
+	}
+
+	quality := opts.Quality
+	if quality <= 0 || quality > 100 {
+		quality = DefaultOptions().Quality
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// orient transforms img according to an EXIF orientation value.
+func orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+// readOrientation returns the orientation tag from a JPEG's EXIF segment,
+// or 0 if the data is not a JPEG or carries no valid tag.
+func readOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return 0
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return 0
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 0
+		}
+		segment := data[pos+4 : pos+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + size
+	}
+	return 0
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF structure.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8:]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 0
+		}
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package preview_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"sidelight/internal/preview"
+)
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts a minimal EXIF APP1 segment carrying the given
+// orientation right after the SOI marker.
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")  // big endian, IFD0 at offset 8
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // one entry
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // value padding + next IFD
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func decodeSize(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Fatalf("expected jpeg output, got %s", format)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestNormalize_Downscales(t *testing.T) {
+	src := encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 4000, 3000)))
+
+	out, err := preview.Normalize(src, 0, preview.Options{LongEdge: 1000, Quality: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := decodeSize(t, out); w != 1000 || h != 750 {
+		t.Errorf("expected 1000x750, got %dx%d", w, h)
+	}
+
+	small := encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 400, 300)))
+	out, _ = preview.Normalize(small, 0, preview.Options{LongEdge: 1000})
+	if w, h := decodeSize(t, out); w != 400 || h != 300 {
+		t.Errorf("small previews must not be upscaled, got %dx%d", w, h)
+	}
+}
+
+func TestNormalize_Orientation(t *testing.T) {
+	src := encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 60, 40)))
+
+	// The embedded tag is honored...
+	out, err := preview.Normalize(withOrientation(src, 6), 1, preview.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := decodeSize(t, out); w != 40 || h != 60 {
+		t.Errorf("embedded orientation 6 should rotate to 40x60, got %dx%d", w, h)
+	}
+
+	// ...and the file's orientation is the fallback for untagged previews.
+	out, _ = preview.Normalize(src, 8, preview.DefaultOptions())
+	if w, h := decodeSize(t, out); w != 40 || h != 60 {
+		t.Errorf("fallback orientation 8 should rotate to 40x60, got %dx%d", w, h)
+	}
+}
+
+func TestNormalize_PNGBecomesJPEG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10)) // fully transparent
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := preview.Normalize(buf.Bytes(), 0, preview.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.GrayModel.Convert(decoded.At(5, 5)).(color.Gray); c.Y < 250 {
+		t.Errorf("transparent pixels should be flattened onto white, got %v", c.Y)
+	}
+
+	if _, err := preview.Normalize([]byte("not an image"), 0, preview.DefaultOptions()); err == nil {
+		t.Error("expected an error for undecodable data")
+	}
+}
+
+func TestContactSheet(t *testing.T) {
+	var previews [][]byte
+	for i := 0; i < 5; i++ {
+		previews = append(previews, encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 300, 200+i*50))))
+	}
+
+	out, err := preview.ContactSheet(previews, preview.Options{LongEdge: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 images make a 3x2 grid of 3:2 cells.
+	w, h := decodeSize(t, out)
+	if w != 600 || h <= 0 || h >= w {
+		t.Errorf("expected a 600px wide landscape sheet, got %dx%d", w, h)
+	}
+
+	if _, err := preview.ContactSheet(nil, preview.DefaultOptions()); err == nil {
+		t.Error("expected an error for an empty series")
+	}
+}
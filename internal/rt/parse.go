@@ -0,0 +1,133 @@
+package rt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"sidelight/pkg/models"
+)
+
+// Profile is a parsed PP3 file: section name -> key -> raw value.
+type Profile map[string]map[string]string
+
+// ParseProfile reads the INI-style structure of a PP3 file.
+func ParseProfile(data []byte) (Profile, error) {
+	profile := make(Profile)
+	var section string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";"):
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			section = text[1 : len(text)-1]
+			if profile[section] == nil {
+				profile[section] = make(map[string]string)
+			}
+		default:
+			key, value, ok := strings.Cut(text, "=")
+			if !ok || section == "" {
+				return nil, fmt.Errorf("invalid PP3 line %d: %q", line, text)
+			}
+			profile[section][strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read PP3: %w", err)
+	}
+	return profile, nil
+}
+
+// Enabled reports whether a section exists and is not disabled.
+func (p Profile) Enabled(section string) bool {
+	s, ok := p[section]
+	return ok && s["Enabled"] != "false"
+}
+
+// Int returns an integer value, or 0 if it is missing or malformed.
+func (p Profile) Int(section, key string) int {
+	return int(math.Round(p.Float(section, key)))
+}
+
+// Float returns a numeric value, or 0 if it is missing or malformed.
+func (p Profile) Float(section, key string) float64 {
+	v, _ := strconv.ParseFloat(p[section][key], 64)
+	return v
+}
+
+// ParsePP3 reads the parameters SideLight controls back from a PP3 file, so a
+// previous grade can serve as the starting point for a refinement. Modules
+// that are disabled in the file read as zero.
+func ParsePP3(data []byte) (*models.PP3Params, error) {
+	p, err := ParseProfile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &models.PP3Params{}
+	if p.Enabled("Exposure") {
+		params.Compensation = p.Float("Exposure", "Compensation")
+		params.Contrast = p.Int("Exposure", "Contrast")
+		params.Saturation = p.Int("Exposure", "Saturation")
+		params.Black = p.Int("Exposure", "Black")
+		params.HighlightCompr = p.Int("Exposure", "HighlightCompr")
+	}
+	if p.Enabled("White Balance") && p["White Balance"]["Setting"] != "Camera" {
+		params.Temperature = p.Int("White Balance", "Temperature")
+		params.Tint = p.Float("White Balance", "Green")
+	}
+	if p.Enabled("Luminance Curve") {
+		params.LabBrightness = p.Int("Luminance Curve", "Brightness")
+		params.LabContrast = p.Int("Luminance Curve", "Contrast")
+		params.LabChromaticity = p.Int("Luminance Curve", "Chromaticity")
+	}
+	if p.Enabled("Vibrance") {
+		params.VibPastels = p.Int("Vibrance", "Pastels")
+		params.VibSaturated = p.Int("Vibrance", "Saturated")
+	}
+	if p.Enabled("Denoise") {
+		params.NRLuminance = p.Int("Denoise", "Luminance")
+		params.NRChrominance = p.Int("Denoise", "Chrominance")
+	}
+	if p.Enabled("SharpenMicro") {
+		params.SharpenMicroStrength = p.Int("SharpenMicro", "Strength")
+		params.SharpenMicroContrast = p.Int("SharpenMicro", "Contrast")
+		params.SharpenMicroUniformity = p.Int("SharpenMicro", "Uniformity")
+	}
+	if p.Enabled("Dehaze") {
+		params.DehazeStrength = p.Int("Dehaze", "Strength")
+	}
+	if p.Enabled("PCVignette") {
+		params.VignetteAmount = int(math.Round(-p.Float("PCVignette", "Strength") * 50))
+		params.VignetteFeather = p.Int("PCVignette", "Feather")
+		params.VignetteRoundness = p.Int("PCVignette", "Roundness")
+	}
+	params.LensProfile = p["LensProfile"]["LcMode"] == "lfauto"
+	if p.Enabled("Black & White") {
+		params.BWEnabled = true
+		params.BWMixerRed = p.Int("Black & White", "MixerRed")
+		params.BWMixerOrange = p.Int("Black & White", "MixerOrange")
+		params.BWMixerYellow = p.Int("Black & White", "MixerYellow")
+		params.BWMixerGreen = p.Int("Black & White", "MixerGreen")
+		params.BWMixerCyan = p.Int("Black & White", "MixerCyan")
+		params.BWMixerBlue = p.Int("Black & White", "MixerBlue")
+		params.BWMixerMagenta = p.Int("Black & White", "MixerMagenta")
+		params.BWMixerPurple = p.Int("Black & White", "MixerPurple")
+	}
+	if p.Enabled("ColorToning") {
+		params.ColorToningShadowR = p.Int("ColorToning", "Redlow")
+		params.ColorToningShadowG = p.Int("ColorToning", "Greenlow")
+		params.ColorToningShadowB = p.Int("ColorToning", "Bluelow")
+		params.ColorToningHighlightR = p.Int("ColorToning", "Redhigh")
+		params.ColorToningHighlightG = p.Int("ColorToning", "Greenhigh")
+		params.ColorToningHighlightB = p.Int("ColorToning", "Bluehigh")
+		params.ColorToningBalance = p.Int("ColorToning", "Balance")/2 + 50
+	}
+	return params, nil
+}
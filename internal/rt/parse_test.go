@@ -0,0 +1,215 @@
+package rt_test
+
+import (
+	"strings"
+	"testing"
+
+	"sidelight/internal/rt"
+	"sidelight/pkg/models"
+)
+
+func TestParsePP3_RoundTrip(t *testing.T) {
+	params := &models.PP3Params{
+		Compensation:         0.55,
+		Contrast:             14,
+		Temperature:          6100,
+		Tint:                 1.02,
+		LabChromaticity:      22,
+		VibPastels:           18,
+		NRLuminance:          12,
+		SharpenMicroStrength: 20,
+		DehazeStrength:       8,
+	}
+
+	got, err := rt.ParsePP3(rt.GeneratePP3FromNative(params, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Compensation != 0.55 || got.Contrast != 14 || got.Temperature != 6100 || got.Tint != 1.02 ||
+		got.LabChromaticity != 22 || got.VibPastels != 18 || got.NRLuminance != 12 ||
+		got.SharpenMicroStrength != 20 || got.DehazeStrength != 8 {
+		t.Errorf("unexpected params %+v", got)
+	}
+}
+
+func TestParsePP3_DisabledModules(t *testing.T) {
+	got, err := rt.ParsePP3([]byte("[White Balance]\nEnabled=false\nTemperature=5000\n\n[Dehaze]\nEnabled=true\nStrength=-10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Temperature != 0 || got.DehazeStrength != -10 {
+		t.Errorf("unexpected params %+v", got)
+	}
+
+	if _, err := rt.ParsePP3([]byte("Compensation=1\n")); err == nil {
+		t.Error("expected an error for a key outside any section")
+	}
+}
+
+func TestGeneratePP3_CameraWhiteBalance(t *testing.T) {
+	data := rt.GeneratePP3(models.GradingParams{Exposure2012: 0.5})
+	if !strings.Contains(string(data), "Setting=Camera") {
+		t.Errorf("As Shot should keep the camera white balance, got:\n%s", data)
+	}
+	got, err := rt.ParsePP3(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Temperature != 0 {
+		t.Errorf("the camera white balance should read back as 0, got %d", got.Temperature)
+	}
+}
+
+func TestGeneratePP3_IPTC(t *testing.T) {
+	params := &models.PP3Params{Scene: &models.SceneInfo{
+		Title:    "Harbor at Dawn",
+		Caption:  "Boats; calm water",
+		Type:     "landscape",
+		Keywords: []string{"harbor", "boats"},
+	}}
+
+	iptc := mustProfile(t, params)["IPTC"]
+	if iptc["Title"] != "Harbor at Dawn;" || iptc["Caption"] != "Boats, calm water;" {
+		t.Errorf("unexpected IPTC title/caption %q / %q", iptc["Title"], iptc["Caption"])
+	}
+	if iptc["Keywords"] != "harbor;boats;landscape;" {
+		t.Errorf("unexpected keywords %q", iptc["Keywords"])
+	}
+
+	if _, ok := mustProfile(t, &models.PP3Params{})["IPTC"]; ok {
+		t.Error("no IPTC section expected without a scene description")
+	}
+}
+
+func mustProfile(t *testing.T, params *models.PP3Params) rt.Profile {
+	t.Helper()
+	profile, err := rt.ParseProfile(rt.GeneratePP3FromNative(params, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+func TestGeneratePP3_Locallab(t *testing.T) {
+	params := &models.PP3Params{LocalAdjustments: []models.LocalAdjustment{
+		{Type: models.MaskRadial, Name: "Face", X1: 0.4, Y1: 0.2, X2: 0.6, Y2: 0.5, Feather: 70, Exposure: 0.5},
+		{Type: models.MaskSky, Exposure: -0.7, Temperature: -20},
+		{Type: "brush", Contrast: 30},
+	}}
+
+	local := mustProfile(t, params)["Locallab"]
+	if local["Enabled"] != "true" || local["Name_0"] != "Face" || local["Shape_0"] != "ELI" {
+		t.Fatalf("unexpected radial spot %v", local)
+	}
+	if local["Centre_0"] != "0;-300;" || local["Loc_0"] != "200;200;300;300;" || local["Transit_0"] != "70" {
+		t.Errorf("unexpected radial geometry centre %q loc %q transit %q", local["Centre_0"], local["Loc_0"], local["Transit_0"])
+	}
+	if local["Expexpose_0"] != "true" || local["Expcomp_0"] != "0.50" || local["Expcolor_0"] != "" {
+		t.Errorf("only the exposure tool should be enabled for spot 0, got %v", local)
+	}
+	if local["Shape_1"] != "RECT" || local["Centre_1"] != "0;-1000;" || local["Warm_1"] != "-20" {
+		t.Errorf("unexpected sky spot %v", local)
+	}
+	if _, ok := local["Name_2"]; ok {
+		t.Error("unknown mask types should be skipped")
+	}
+
+	if _, ok := mustProfile(t, &models.PP3Params{})["Locallab"]; ok {
+		t.Error("no Locallab section expected without local adjustments")
+	}
+}
+
+func TestGeneratePP3_Crop(t *testing.T) {
+	params := &models.PP3Params{Crop: &models.CropSuggestion{Top: 0.1, Left: 0.05, Bottom: 0.9, Right: 0.95, Angle: 1.2, Width: 6000, Height: 4000}}
+
+	profile := mustProfile(t, params)
+	if profile["Rotation"]["Degree"] != "-1.20" || profile["Common Properties for Transformations"]["AutoFill"] != "true" {
+		t.Errorf("unexpected rotation %v", profile["Rotation"])
+	}
+	crop := profile["Crop"]
+	if crop["Enabled"] != "true" || crop["X"] != "300" || crop["Y"] != "400" || crop["W"] != "5400" || crop["H"] != "3200" {
+		t.Errorf("unexpected crop %v", crop)
+	}
+
+	// Without the image size only the rotation can be written.
+	params.Crop.Width, params.Crop.Height = 0, 0
+	if _, ok := mustProfile(t, params)["Crop"]; ok {
+		t.Error("no crop expected without the image size")
+	}
+}
+
+func TestGeneratePP3_VignetteAndLens(t *testing.T) {
+	params := &models.PP3Params{VignetteAmount: -40, VignetteFeather: 70, LensProfile: true}
+
+	profile := mustProfile(t, params)
+	vignette := profile["PCVignette"]
+	if vignette["Enabled"] != "true" || vignette["Strength"] != "0.80" || vignette["Feather"] != "70" || vignette["Roundness"] != "50" {
+		t.Errorf("unexpected vignette %v", vignette)
+	}
+	if profile["LensProfile"]["LcMode"] != "lfauto" {
+		t.Errorf("expected automatic lens profile, got %v", profile["LensProfile"])
+	}
+
+	got, err := rt.ParsePP3(rt.GeneratePP3FromNative(params, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VignetteAmount != -40 || got.VignetteFeather != 70 || !got.LensProfile {
+		t.Errorf("vignette and lens profile should survive a round trip, got %+v", got)
+	}
+
+	profile = mustProfile(t, &models.PP3Params{})
+	if _, ok := profile["PCVignette"]; ok {
+		t.Error("no vignette expected without an amount")
+	}
+	if _, ok := profile["LensProfile"]; ok {
+		t.Error("no lens profile expected unless enabled")
+	}
+}
+
+func TestGeneratePP3_BlackAndWhite(t *testing.T) {
+	params := &models.PP3Params{
+		Saturation: -100, BWEnabled: true, BWMixerRed: 60, BWMixerBlue: -20,
+		ColorToningShadowR: 10, ColorToningShadowB: -8, ColorToningHighlightR: 6, ColorToningBalance: 60,
+	}
+
+	profile := mustProfile(t, params)
+	bw := profile["Black & White"]
+	if bw["Enabled"] != "true" || bw["Method"] != "ChannelMixer" || bw["MixerRed"] != "60" || bw["MixerBlue"] != "-20" || bw["MixerGreen"] != "33" {
+		t.Errorf("unexpected black and white module %v", bw)
+	}
+	if profile["Exposure"]["Saturation"] != "0" {
+		t.Errorf("the channel mixer should get the full colors, got saturation %s", profile["Exposure"]["Saturation"])
+	}
+	toning := profile["ColorToning"]
+	if toning["Enabled"] != "true" || toning["Redlow"] != "10" || toning["Bluelow"] != "-8" || toning["Redhigh"] != "6" || toning["Balance"] != "20" {
+		t.Errorf("unexpected color toning %v", toning)
+	}
+
+	got, err := rt.ParsePP3(rt.GeneratePP3FromNative(params, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.BWEnabled || got.BWMixerRed != 60 || got.BWMixerBlue != -20 || got.ColorToningShadowR != 10 || got.ColorToningBalance != 60 {
+		t.Errorf("black and white and toning should survive a round trip, got %+v", got)
+	}
+
+	profile = mustProfile(t, &models.PP3Params{})
+	if _, ok := profile["Black & White"]; ok {
+		t.Error("no black and white module expected unless enabled")
+	}
+	if _, ok := profile["ColorToning"]; ok {
+		t.Error("no color toning expected without toning values")
+	}
+}
+
+func TestGeneratePP3_GrayMixerFallback(t *testing.T) {
+	profile, err := rt.ParseProfile(rt.GeneratePP3(models.GradingParams{ConvertToGrayscale: true, GrayMixerBlue: -60, GrayMixerOrange: 30}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bw := profile["Black & White"]
+	if bw["Enabled"] != "true" || bw["MixerBlue"] != "13" || bw["MixerOrange"] != "43" || bw["MixerRed"] != "33" {
+		t.Errorf("unexpected black and white module %v", bw)
+	}
+}
@@ -8,6 +8,14 @@ import (
 	"sidelight/pkg/models"
 )
 
+// orDefault returns def for an unset (zero) value
+func orDefault(val, def int) int {
+	if val == 0 {
+		return def
+	}
+	return val
+}
+
 // clamp limits a value to a given range
 func clamp(val, min, max int) int {
 	if val < min {
@@ -274,8 +282,8 @@ func sanitizeParams(params *models.PP3Params) {
 	}
 
 	// === WHITE BALANCE ===
-	// Temperature: allow cooler temps for Fuji-style looks
-	if params.Temperature < 4200 {
+	// Temperature: allow cooler temps for Fuji-style looks; 0 keeps the camera's
+	if params.Temperature != 0 && params.Temperature < 4200 {
 		params.Temperature = 4200
 	}
 	if params.Temperature > 7500 {
@@ -366,7 +374,11 @@ func GeneratePP3FromNative(params *models.PP3Params, isRaw bool) []byte {
 	sb.WriteString("Enabled=true\n")
 	sb.WriteString(fmt.Sprintf("Compensation=%.2f\n", params.Compensation))
 	sb.WriteString(fmt.Sprintf("Contrast=%d\n", clamp(params.Contrast, -50, 50)))
-	sb.WriteString(fmt.Sprintf("Saturation=%d\n", clamp(params.Saturation, -50, 50)))
+	saturation := params.Saturation
+	if params.BWEnabled {
+		saturation = 0 // the channel mixer needs the colors to weight them
+	}
+	sb.WriteString(fmt.Sprintf("Saturation=%d\n", clamp(saturation, -50, 50)))
 	sb.WriteString(fmt.Sprintf("Black=%d\n", clamp(params.Black, 0, 300)))
 	sb.WriteString(fmt.Sprintf("HighlightCompr=%d\n", clamp(params.HighlightCompr, 0, 200)))
 	sb.WriteString("HighlightComprThreshold=0\n")
@@ -384,7 +396,11 @@ func GeneratePP3FromNative(params *models.PP3Params, isRaw bool) []byte {
 
 	// === WHITE BALANCE ===
 	sb.WriteString("[White Balance]\n")
-	if isRaw {
+	if isRaw && params.Temperature == 0 {
+		// No correction: keep the white balance recorded by the camera
+		sb.WriteString("Enabled=true\n")
+		sb.WriteString("Setting=Camera\n\n")
+	} else if isRaw {
 		sb.WriteString("Enabled=true\n")
 		sb.WriteString("Setting=Custom\n")
 		temp := params.Temperature
@@ -523,11 +539,94 @@ func GeneratePP3FromNative(params *models.PP3Params, isRaw bool) []byte {
 
 	// === RESIZE ===
 	sb.WriteString("[Resize]\n")
-	sb.WriteString("Enabled=false\n")
+	sb.WriteString("Enabled=false\n\n")
+
+	// === BLACK & WHITE (channel mixer) ===
+	// Saturation in [Exposure] stops at -50, so monochrome needs this module
+	if params.BWEnabled {
+		writeBlackAndWhite(&sb, params)
+	}
+
+	// === COLOR TONING (split toning, also tones a black and white image) ===
+	if params.ColorToningShadowR != 0 || params.ColorToningShadowG != 0 || params.ColorToningShadowB != 0 ||
+		params.ColorToningHighlightR != 0 || params.ColorToningHighlightG != 0 || params.ColorToningHighlightB != 0 {
+		sb.WriteString("[ColorToning]\n")
+		sb.WriteString("Enabled=true\n")
+		sb.WriteString("Method=RGBSliders\n")
+		sb.WriteString(fmt.Sprintf("Redlow=%d\n", clamp(params.ColorToningShadowR, -100, 100)))
+		sb.WriteString(fmt.Sprintf("Greenlow=%d\n", clamp(params.ColorToningShadowG, -100, 100)))
+		sb.WriteString(fmt.Sprintf("Bluelow=%d\n", clamp(params.ColorToningShadowB, -100, 100)))
+		sb.WriteString(fmt.Sprintf("Redhigh=%d\n", clamp(params.ColorToningHighlightR, -100, 100)))
+		sb.WriteString(fmt.Sprintf("Greenhigh=%d\n", clamp(params.ColorToningHighlightG, -100, 100)))
+		sb.WriteString(fmt.Sprintf("Bluehigh=%d\n", clamp(params.ColorToningHighlightB, -100, 100)))
+		// ct_balance is 0 to 100 with 50 neutral, RT's Balance -100 to 100
+		sb.WriteString(fmt.Sprintf("Balance=%d\n\n", (clamp(params.ColorToningBalance, 0, 100)-50)*2))
+	}
+
+	// === POST-CROP VIGNETTE ===
+	// RT darkens the corners for positive strengths (in stops)
+	if params.VignetteAmount != 0 {
+		sb.WriteString("[PCVignette]\n")
+		sb.WriteString("Enabled=true\n")
+		sb.WriteString(fmt.Sprintf("Strength=%.2f\n", -float64(clamp(params.VignetteAmount, -100, 100))/50))
+		sb.WriteString(fmt.Sprintf("Feather=%d\n", clamp(orDefault(params.VignetteFeather, 50), 0, 100)))
+		sb.WriteString(fmt.Sprintf("Roundness=%d\n\n", clamp(orDefault(params.VignetteRoundness, 50), 0, 100)))
+	}
+
+	// === LENS PROFILE (distortion and vignetting from lensfun) ===
+	// Lateral CA is already corrected in [RAW] for raw files
+	if params.LensProfile {
+		sb.WriteString("[LensProfile]\n")
+		sb.WriteString("LcMode=lfauto\n")
+		sb.WriteString("UseDistortion=true\n")
+		sb.WriteString("UseVignette=true\n")
+		sb.WriteString("UseCA=false\n\n")
+	}
+
+	// === CROP & ROTATION (composition) ===
+	if params.Crop != nil {
+		writeCrop(&sb, params.Crop)
+	}
+
+	// === LOCALLAB (masked local adjustments) ===
+	writeLocallab(&sb, params.LocalAdjustments)
+
+	// === IPTC (scene description) ===
+	if params.Scene != nil {
+		writeIPTC(&sb, params.Scene)
+	}
 
 	return []byte(sb.String())
 }
 
+// writeIPTC writes the scene description as RawTherapee IPTC metadata. RT
+// stores each field as a list of values terminated by ';'.
+func writeIPTC(sb *strings.Builder, scene *models.SceneInfo) {
+	list := func(values ...string) string {
+		var out strings.Builder
+		for _, v := range values {
+			v = strings.NewReplacer(";", ",", "\n", " ", "\r", " ").Replace(strings.TrimSpace(v))
+			if v != "" {
+				out.WriteString(v + ";")
+			}
+		}
+		return out.String()
+	}
+
+	sb.WriteString("[IPTC]\n")
+	if scene.Title != "" {
+		sb.WriteString("Title=" + list(scene.Title) + "\n")
+		sb.WriteString("Headline=" + list(scene.Title) + "\n")
+	}
+	if scene.Caption != "" {
+		sb.WriteString("Caption=" + list(scene.Caption) + "\n")
+	}
+	if keywords := scene.Subjects(); len(keywords) > 0 {
+		sb.WriteString("Keywords=" + list(keywords...) + "\n")
+	}
+	sb.WriteString("\n")
+}
+
 // GeneratePP3 creates a RawTherapee sidecar from Adobe-style GradingParams
 // This is the fallback method with parameter conversion
 func GeneratePP3(params models.GradingParams) []byte {
@@ -552,10 +651,8 @@ func GeneratePP3(params models.GradingParams) []byte {
 	// === WHITE BALANCE CONVERSION ===
 	// Adobe Temperature: 2000-50000K
 	// RT Temperature: same range, direct mapping
+	// 0 (As Shot) is kept and written as the camera white balance
 	temperature := params.Temperature
-	if temperature == 0 {
-		temperature = 5500 // Default daylight
-	}
 
 	// Adobe Tint: -150 to +150 (positive = magenta, negative = green)
 	// RT Green: 0.02 to 10.0 (>1.0 = green, <1.0 = magenta)
@@ -675,6 +772,7 @@ func GeneratePP3(params models.GradingParams) []byte {
 	// We need to approximate this with RT's tone curve
 	toneCurve := buildToneCurveFromAdobe(params)
 
+	// Grain has no RawTherapee equivalent and is not converted
 	pp3 := &models.PP3Params{
 		Compensation:           compensation,
 		Contrast:               contrast,
@@ -699,7 +797,13 @@ func GeneratePP3(params models.GradingParams) []byte {
 		NRLuminance:            params.LuminanceSmoothing,
 		NRChrominance:          params.ColorNoiseReduction,
 		VignetteAmount:         params.PostCropVignetteAmount,
+		VignetteFeather:        params.PostCropVignetteFeather,
+		VignetteRoundness:      clamp((params.PostCropVignetteRoundness+100)/2, 0, 100),
+		LensProfile:            params.LensProfileEnable == 1,
+		BWEnabled:              params.ConvertToGrayscale,
 		ToneCurve:              toneCurve,
+		LocalAdjustments:       params.LocalAdjustments,
+		Crop:                   params.Crop,
 	}
 
 	// Color toning from split toning
@@ -715,6 +819,20 @@ func GeneratePP3(params models.GradingParams) []byte {
 		pp3.ColorToningBalance = 50 + params.SplitToningBalance/2
 	}
 
+	// Gray mixer: Lightroom shifts each color's brightness by -100 to 100, RT
+	// weights it around 33
+	if params.ConvertToGrayscale {
+		weight := func(v int) int { return 33 + clamp(v, -100, 100)/3 }
+		pp3.BWMixerRed = weight(params.GrayMixerRed)
+		pp3.BWMixerOrange = weight(params.GrayMixerOrange)
+		pp3.BWMixerYellow = weight(params.GrayMixerYellow)
+		pp3.BWMixerGreen = weight(params.GrayMixerGreen)
+		pp3.BWMixerCyan = weight(params.GrayMixerAqua)
+		pp3.BWMixerBlue = weight(params.GrayMixerBlue)
+		pp3.BWMixerMagenta = weight(params.GrayMixerMagenta)
+		pp3.BWMixerPurple = weight(params.GrayMixerPurple)
+	}
+
 	// Assume RAW for Adobe conversion fallback, as Adobe params (like Temp K) are RAW-centric
 	return GeneratePP3FromNative(pp3, true)
 }
@@ -817,3 +935,163 @@ func hueToRGB(hue, saturation int) (r, g, b int) {
 
 	return clamp(r, -100, 100), clamp(g, -100, 100), clamp(b, -100, 100)
 }
+
+// locallabSpot is the area of a RawTherapee Locallab spot. RT measures spots
+// in units where the image spans -1000 to 1000 on both axes: the centre is a
+// position, the four extents are distances from it.
+type locallabSpot struct {
+	centreX, centreY         int
+	right, left, bottom, top int
+	shape                    string // ELI or RECT
+	transit                  int    // width of the fade at the edge, 2 to 100
+}
+
+// newLocallabSpot approximates a local adjustment's mask with a spot. RT has
+// no graduated or AI masks in Locallab: a linear gradient becomes a
+// rectangle centred on its full end that fades out towards the zero end, the
+// sky a gradient from the top edge, and the subject an ellipse over the
+// middle of the frame.
+func newLocallabSpot(a models.LocalAdjustment) (locallabSpot, bool) {
+	pos := func(v float64) int { return int(math.Round(clampFloat(v, 0, 1)*2000 - 1000)) }
+	extent := func(d float64) int { return clamp(int(math.Round(math.Abs(d)*2000)), 2, 3000) }
+
+	switch strings.ToLower(strings.TrimSpace(a.Type)) {
+	case models.MaskSky:
+		a.X1, a.Y1, a.X2, a.Y2 = 0.5, 0, 0.5, 0.5
+		fallthrough
+	case models.MaskLinear:
+		// Along an axis the gradient does not run, the spot covers the frame.
+		w, h := extent(a.X2-a.X1), extent(a.Y2-a.Y1)
+		if math.Abs(a.X2-a.X1) < 0.05 {
+			w = 3000
+		}
+		if math.Abs(a.Y2-a.Y1) < 0.05 {
+			h = 3000
+		}
+		return locallabSpot{centreX: pos(a.X1), centreY: pos(a.Y1), right: w, left: w, bottom: h, top: h, shape: "RECT", transit: 100}, true
+	case models.MaskSubject:
+		a.X1, a.Y1, a.X2, a.Y2, a.Feather = 0.2, 0.1, 0.8, 0.9, 50
+		fallthrough
+	case models.MaskRadial:
+		w, h := extent((a.X2-a.X1)/2), extent((a.Y2-a.Y1)/2)
+		transit := a.Feather
+		if transit == 0 {
+			transit = 50
+		}
+		return locallabSpot{
+			centreX: pos((a.X1 + a.X2) / 2), centreY: pos((a.Y1 + a.Y2) / 2),
+			right: w, left: w, bottom: h, top: h, shape: "ELI", transit: clamp(transit, 2, 100),
+		}, true
+	default:
+		return locallabSpot{}, false
+	}
+}
+
+// writeLocallab writes local adjustments as RawTherapee Locallab spots, one
+// per adjustment, with only the tools they use enabled: Exposure for
+// exposure, Color & Light for contrast and Vibrance & Warm/Cool for
+// temperature. Keys RT does not find keep their defaults. Adjustments of an
+// unknown type are skipped.
+func writeLocallab(sb *strings.Builder, adjustments []models.LocalAdjustment) {
+	var spots strings.Builder
+	n := 0
+	for _, a := range adjustments {
+		spot, ok := newLocallabSpot(a)
+		if !ok {
+			continue
+		}
+		name := strings.NewReplacer("\n", " ", "\r", " ").Replace(strings.TrimSpace(a.Name))
+		if name == "" {
+			name = fmt.Sprintf("Spot %d", n+1)
+		}
+
+		fmt.Fprintf(&spots, "Name_%d=%s\n", n, name)
+		fmt.Fprintf(&spots, "IsVisible_%d=true\n", n)
+		fmt.Fprintf(&spots, "Shape_%d=%s\n", n, spot.shape)
+		fmt.Fprintf(&spots, "SpotMethod_%d=norm\n", n)
+		fmt.Fprintf(&spots, "ShapeMethod_%d=IND\n", n)
+		fmt.Fprintf(&spots, "Loc_%d=%d;%d;%d;%d;\n", n, spot.right, spot.left, spot.bottom, spot.top)
+		fmt.Fprintf(&spots, "Centre_%d=%d;%d;\n", n, spot.centreX, spot.centreY)
+		fmt.Fprintf(&spots, "Transit_%d=%d\n", n, spot.transit)
+		fmt.Fprintf(&spots, "Activ_%d=true\n", n)
+		if a.Exposure != 0 {
+			fmt.Fprintf(&spots, "Expexpose_%d=true\n", n)
+			fmt.Fprintf(&spots, "Expcomp_%d=%.2f\n", n, clampFloat(a.Exposure, -2, 4))
+		}
+		if a.Contrast != 0 {
+			fmt.Fprintf(&spots, "Expcolor_%d=true\n", n)
+			fmt.Fprintf(&spots, "Contrast_%d=%d\n", n, clamp(a.Contrast, -100, 100))
+		}
+		if a.Temperature != 0 {
+			fmt.Fprintf(&spots, "Expvibrance_%d=true\n", n)
+			fmt.Fprintf(&spots, "Warm_%d=%d\n", n, clamp(a.Temperature, -100, 100))
+		}
+		n++
+	}
+	if n == 0 {
+		return
+	}
+
+	sb.WriteString("[Locallab]\n")
+	sb.WriteString("Enabled=true\n")
+	sb.WriteString("Selspot=0\n")
+	sb.WriteString(spots.String())
+	sb.WriteString("\n")
+}
+
+// writeCrop writes a crop suggestion as RawTherapee [Rotation] and [Crop]
+// sections. RawTherapee rotates counter-clockwise for positive degrees and
+// crops in pixels, so the crop is left out when the image size is unknown.
+// Auto-fill scales the rotated image to leave no blank corners.
+func writeCrop(sb *strings.Builder, crop *models.CropSuggestion) {
+	if crop.Angle != 0 {
+		sb.WriteString("[Rotation]\n")
+		sb.WriteString(fmt.Sprintf("Degree=%.2f\n\n", -clampFloat(crop.Angle, -45, 45)))
+		sb.WriteString("[Common Properties for Transformations]\n")
+		sb.WriteString("AutoFill=true\n\n")
+	}
+
+	if crop.Width <= 0 || crop.Height <= 0 {
+		return
+	}
+	x := int(math.Round(clampFloat(crop.Left, 0, 1) * float64(crop.Width)))
+	y := int(math.Round(clampFloat(crop.Top, 0, 1) * float64(crop.Height)))
+	w := int(math.Round(clampFloat(crop.Right, 0, 1)*float64(crop.Width))) - x
+	h := int(math.Round(clampFloat(crop.Bottom, 0, 1)*float64(crop.Height))) - y
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	sb.WriteString("[Crop]\n")
+	sb.WriteString("Enabled=true\n")
+	sb.WriteString(fmt.Sprintf("X=%d\n", x))
+	sb.WriteString(fmt.Sprintf("Y=%d\n", y))
+	sb.WriteString(fmt.Sprintf("W=%d\n", w))
+	sb.WriteString(fmt.Sprintf("H=%d\n", h))
+	sb.WriteString("FixedRatio=false\n")
+	sb.WriteString("Orientation=As Image\n")
+	sb.WriteString("Guide=Frame\n\n")
+}
+
+// writeBlackAndWhite writes RawTherapee's black and white module in channel
+// mixer mode. Each mixer value is the weight of a color in the gray value;
+// unset values keep RT's default of 33.
+func writeBlackAndWhite(sb *strings.Builder, params *models.PP3Params) {
+	mixer := func(v int) int { return clamp(orDefault(v, 33), -100, 200) }
+	sb.WriteString("[Black & White]\n")
+	sb.WriteString("Enabled=true\n")
+	sb.WriteString("Method=ChannelMixer\n")
+	sb.WriteString("Auto=false\n")
+	sb.WriteString("ComplementaryColors=true\n")
+	sb.WriteString("Setting=RGB-Rel\n")
+	sb.WriteString("Filter=None\n")
+	sb.WriteString(fmt.Sprintf("MixerRed=%d\n", mixer(params.BWMixerRed)))
+	sb.WriteString(fmt.Sprintf("MixerOrange=%d\n", mixer(params.BWMixerOrange)))
+	sb.WriteString(fmt.Sprintf("MixerYellow=%d\n", mixer(params.BWMixerYellow)))
+	sb.WriteString(fmt.Sprintf("MixerGreen=%d\n", mixer(params.BWMixerGreen)))
+	sb.WriteString(fmt.Sprintf("MixerCyan=%d\n", mixer(params.BWMixerCyan)))
+	sb.WriteString(fmt.Sprintf("MixerBlue=%d\n", mixer(params.BWMixerBlue)))
+	sb.WriteString(fmt.Sprintf("MixerMagenta=%d\n", mixer(params.BWMixerMagenta)))
+	sb.WriteString(fmt.Sprintf("MixerPurple=%d\n", mixer(params.BWMixerPurple)))
+	sb.WriteString("Algorithm=SP\n\n")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -18,6 +19,7 @@ import (
 	"sidelight/internal/ai"
 	"sidelight/internal/app"
 	"sidelight/internal/extractor"
+	"sidelight/internal/style"
 	"sidelight/pkg/models"
 )
 
@@ -28,6 +30,7 @@ type Server struct {
 	processor *app.Processor
 	port      int
 	outputDir string
+	Styles    *style.Registry // grading styles accepted by /api/grade, defaults to the built-in set
 }
 
 type GradeResponse struct {
@@ -64,6 +67,7 @@ func NewServer(processor *app.Processor, port int) *Server {
 		processor: processor,
 		port:      port,
 		outputDir: outDir,
+		Styles:    style.Builtin(),
 	}
 }
 
@@ -85,6 +89,23 @@ func (s *Server) Start() error {
 	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), nil)
 }
 
+// errorStatus maps a processing failure to an HTTP status. Problems with the
+// upload are the client's; problems with the AI backend are a bad gateway.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ai.ErrSafetyBlocked):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ai.ErrQuota):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ai.ErrAuth), errors.Is(err, ai.ErrMalformedResponse), errors.Is(err, ai.ErrEmptyResponse):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (s *Server) handleGrade(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -104,9 +125,10 @@ func (s *Server) handleGrade(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	style := r.FormValue("style")
-	if style == "" {
-		style = "natural"
+	styleDef, err := s.Styles.Get(r.FormValue("style"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 	prompt := r.FormValue("prompt")
 
@@ -141,7 +163,7 @@ func (s *Server) handleGrade(w http.ResponseWriter, r *http.Request) {
 	// Update processor formats to generate PP3 for rendering
 	s.processor.Formats = []string{"pp3"}
 	
-	log.Printf("Processing file: %s (Style: %s)", tempPath, style)
+	log.Printf("Processing file: %s (Style: %s)", tempPath, styleDef.Name)
 
 	// DEBUG: Verify preview extraction manually first
 	debugPreview, err := extractor.NewExifToolExtractor().ExtractPreview(ctx, tempPath)
@@ -155,12 +177,13 @@ func (s *Server) handleGrade(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	result, err := s.processor.ProcessFile(ctx, tempPath, ai.AnalysisOptions{
-		Style:      style,
+		Style:      styleDef.Name,
 		UserPrompt: prompt,
+		Definition: styleDef,
 	})
 	if err != nil {
 		log.Printf("Processing error: %v", err)
-		http.Error(w, "Processing failed: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Processing failed: "+err.Error(), errorStatus(err))
 		return
 	}
 
@@ -0,0 +1,317 @@
+// Package style holds the registry of grading styles. Each style carries a
+// prompt hint for the Lightroom and the RawTherapee analysis and optional
+// per-parameter bounds that the result is clamped to.
+package style
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sidelight/internal/xmp"
+	"sidelight/pkg/models"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Default is the style used when none is requested.
+const Default = "natural"
+
+// Auto is the pseudo style that picks a registered style for each photo.
+const Auto = "auto"
+
+// ErrUnknownStyle is returned when a style name is not in the registry.
+var ErrUnknownStyle = errors.New("unknown style")
+
+// Bound limits one parameter. Either side may be omitted.
+type Bound struct {
+	Min *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// Bounds are keyed by the JSON parameter names of models.GradingParams (LR)
+// and models.PP3Params (PP3), e.g. "saturation".
+type Bounds struct {
+	LR  map[string]Bound `json:"lr,omitempty" yaml:"lr,omitempty"`
+	PP3 map[string]Bound `json:"pp3,omitempty" yaml:"pp3,omitempty"`
+}
+
+// Style is one grading style definition.
+type Style struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	LR          string `json:"lr" yaml:"lr"`   // prompt hint for Adobe Camera Raw grading
+	PP3         string `json:"pp3" yaml:"pp3"` // prompt hint for RawTherapee grading
+	Bounds      Bounds `json:"bounds,omitempty" yaml:"bounds,omitempty"`
+	Monochrome  bool   `json:"monochrome,omitempty" yaml:"monochrome,omitempty"` // always convert to grayscale
+	Profile     string `json:"profile,omitempty" yaml:"profile,omitempty"`       // base camera profile for XMP, see xmp.ResolveProfile
+
+	Source string `json:"-" yaml:"-"` // "builtin" or the file the style was loaded from
+}
+
+//go:embed builtin.yaml
+var builtinYAML []byte
+
+// Registry is a set of styles by name.
+type Registry struct {
+	styles map[string]*Style
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{styles: make(map[string]*Style)}
+}
+
+// Builtin returns a registry with the styles shipped with SideLight.
+func Builtin() *Registry {
+	r := NewRegistry()
+	var defs []*Style
+	if err := yaml.Unmarshal(builtinYAML, &defs); err != nil {
+		panic(fmt.Sprintf("invalid builtin styles: %v", err))
+	}
+	for _, s := range defs {
+		s.Source = "builtin"
+		r.Add(s)
+	}
+	return r
+}
+
+// DefaultDir returns the user style directory, ~/.config/sidelight/styles.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sidelight", "styles"), nil
+}
+
+// Load returns the built-in styles extended (or overridden) by the style
+// files found in dirs. Missing directories are ignored.
+func Load(dirs ...string) (*Registry, error) {
+	r := Builtin()
+	for _, dir := range dirs {
+		if err := r.LoadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LoadDir adds every *.json, *.yaml and *.yml file in dir to the registry.
+// A file without a name is registered under its base name.
+func (r *Registry) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read style directory %s: %w", dir, err)
+	}
+
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		s, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		r.Add(s)
+	}
+	return nil
+}
+
+// LoadFile parses a single JSON or YAML style definition.
+func LoadFile(path string) (*Style, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read style file %s: %w", path, err)
+	}
+
+	s := &Style{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, s)
+	} else {
+		err = yaml.Unmarshal(data, s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse style file %s: %w", path, err)
+	}
+
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if strings.EqualFold(s.Name, Auto) {
+		return nil, fmt.Errorf("style file %s: the name %q is reserved", path, Auto)
+	}
+	if s.LR == "" && s.PP3 == "" {
+		return nil, fmt.Errorf("style file %s defines neither an lr nor a pp3 hint", path)
+	}
+	if err := s.Bounds.check(); err != nil {
+		return nil, fmt.Errorf("style file %s: %w", path, err)
+	}
+	if s.Profile != "" && !xmp.IsProfile(s.Profile) {
+		return nil, fmt.Errorf("style file %s: unknown camera profile %q", path, s.Profile)
+	}
+	s.Source = path
+	return s, nil
+}
+
+// Add registers s, replacing any style with the same name.
+func (r *Registry) Add(s *Style) {
+	s.Name = strings.ToLower(s.Name)
+	r.styles[s.Name] = s
+}
+
+// Get returns the named style. An empty name selects the default style.
+func (r *Registry) Get(name string) (*Style, error) {
+	if name == "" {
+		name = Default
+	}
+	s, ok := r.styles[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownStyle, name, strings.Join(r.Names(), ", "))
+	}
+	return s, nil
+}
+
+// Names returns the registered style names in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.styles))
+	for name := range r.styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns the registered styles in alphabetical order.
+func (r *Registry) List() []*Style {
+	var list []*Style
+	for _, name := range r.Names() {
+		list = append(list, r.styles[name])
+	}
+	return list
+}
+
+// Hint returns the prompt hint for the given output ("lr" or "pp3"). A style
+// that only defines one of them is used for both.
+func (s *Style) Hint(kind string) string {
+	primary, fallback := s.LR, s.PP3
+	if kind == "pp3" {
+		primary, fallback = s.PP3, s.LR
+	}
+	if primary == "" {
+		return fallback
+	}
+	return primary
+}
+
+// check rejects bounds on unknown parameters or non-numeric fields.
+func (b Bounds) check() error {
+	for kind, set := range map[string]map[string]Bound{"lr": b.LR, "pp3": b.PP3} {
+		fields := numericFields(targetType(kind))
+		for name, bound := range set {
+			if _, ok := fields[name]; !ok {
+				return fmt.Errorf("bounds.%s: unknown numeric parameter %q", kind, name)
+			}
+			if bound.Min != nil && bound.Max != nil && *bound.Min > *bound.Max {
+				return fmt.Errorf("bounds.%s.%s: min is greater than max", kind, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Describe renders bounds as a prompt fragment, e.g. "- saturation: -100 to -100".
+func Describe(bounds map[string]Bound) string {
+	names := make([]string, 0, len(bounds))
+	for name := range bounds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		b := bounds[name]
+		switch {
+		case b.Min != nil && b.Max != nil:
+			fmt.Fprintf(&sb, "- %s: %g to %g\n", name, *b.Min, *b.Max)
+		case b.Min != nil:
+			fmt.Fprintf(&sb, "- %s: at least %g\n", name, *b.Min)
+		case b.Max != nil:
+			fmt.Fprintf(&sb, "- %s: at most %g\n", name, *b.Max)
+		}
+	}
+	return sb.String()
+}
+
+// Apply clamps the numeric fields of params (a *models.GradingParams or
+// *models.PP3Params) to the bounds. Unknown names and unset fields are ignored.
+func Apply(bounds map[string]Bound, params interface{}) {
+	v := reflect.ValueOf(params)
+	if len(bounds) == 0 || v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	v = v.Elem()
+
+	for _, spec := range models.Fields(v.Type()) {
+		bound, ok := bounds[spec.Name]
+		if !ok {
+			continue
+		}
+		f := v.FieldByIndex(spec.Index)
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				continue
+			}
+			f = f.Elem()
+		}
+		switch f.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat(bound.clamp(f.Float()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(bound.clamp(float64(f.Int()))))
+		}
+	}
+}
+
+func (b Bound) clamp(v float64) float64 {
+	if b.Min != nil && v < *b.Min {
+		v = *b.Min
+	}
+	if b.Max != nil && v > *b.Max {
+		v = *b.Max
+	}
+	return v
+}
+
+func targetType(kind string) reflect.Type {
+	if kind == "pp3" {
+		return reflect.TypeOf(models.PP3Params{})
+	}
+	return reflect.TypeOf(models.GradingParams{})
+}
+
+func numericFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for _, spec := range models.Fields(t) {
+		k := spec.Type.Kind()
+		if k == reflect.Ptr {
+			k = spec.Type.Elem().Kind()
+		}
+		switch k {
+		case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fields[spec.Name] = true
+		}
+	}
+	return fields
+}
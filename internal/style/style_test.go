@@ -0,0 +1,127 @@
+package style_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sidelight/internal/style"
+	"sidelight/pkg/models"
+)
+
+func TestBuiltin_DefinesBothHints(t *testing.T) {
+	registry := style.Builtin()
+	if len(registry.Names()) < 30 {
+		t.Fatalf("expected at least 30 built-in styles, got %d", len(registry.Names()))
+	}
+	for _, s := range registry.List() {
+		if s.LR == "" || s.PP3 == "" {
+			t.Errorf("style %q is missing an lr or pp3 hint", s.Name)
+		}
+	}
+	if _, err := registry.Get(""); err != nil {
+		t.Errorf("empty name should select the default style: %v", err)
+	}
+}
+
+func TestRegistry_UnknownStyle(t *testing.T) {
+	_, err := style.Builtin().Get("no-such-style")
+	if !errors.Is(err, style.ErrUnknownStyle) {
+		t.Fatalf("expected ErrUnknownStyle, got %v", err)
+	}
+}
+
+func TestLoadDir_UserStylesOverrideBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("moody.yaml", "description: Dark and moody\nlr: Deep shadows.\nbounds:\n  lr:\n    exposure: {max: 0}\n")
+	write("natural.json", `{"name": "natural", "lr": "My natural.", "pp3": "My natural RT."}`)
+	write("notes.txt", "ignored")
+
+	registry, err := style.Load(dir, filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moody, err := registry.Get("moody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moody.Source != filepath.Join(dir, "moody.yaml") || moody.Hint("pp3") != "Deep shadows." {
+		t.Errorf("unexpected user style %+v", moody)
+	}
+
+	natural, _ := registry.Get("natural")
+	if natural.LR != "My natural." {
+		t.Errorf("user style should override the built-in, got %q", natural.LR)
+	}
+}
+
+func TestLoadFile_RejectsUnknownBounds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(path, []byte("lr: x\nbounds:\n  lr:\n    exposur: {max: 1}\n"), 0644)
+	if _, err := style.LoadFile(path); err == nil {
+		t.Error("expected an error for a misspelled bound")
+	}
+}
+
+func TestLoadFile_RejectsReservedName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auto.yaml")
+	if err := os.WriteFile(path, []byte("lr: Anything.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := style.LoadFile(path); err == nil {
+		t.Error("a style named auto would shadow automatic selection")
+	}
+}
+
+func TestApply(t *testing.T) {
+	lo, hi := -100.0, -100.0
+	maxExposure := 0.5
+	bounds := map[string]style.Bound{
+		"saturation": {Min: &lo, Max: &hi},
+		"exposure":   {Max: &maxExposure},
+	}
+
+	params := &models.GradingParams{Saturation: 20, Exposure2012: 1.2, Contrast2012: 30}
+	style.Apply(bounds, params)
+	if params.Saturation != -100 || params.Exposure2012 != 0.5 || params.Contrast2012 != 30 {
+		t.Errorf("unexpected clamped params %+v", params)
+	}
+}
+
+func TestBuiltin_Monochrome(t *testing.T) {
+	registry := style.Builtin()
+	for name, want := range map[string]bool{"bw": true, "bw-contrast": true, "bw-soft": true, "bw-sepia": true, "cinematic": false} {
+		s, err := registry.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Monochrome != want {
+			t.Errorf("style %q: monochrome = %v, want %v", name, s.Monochrome, want)
+		}
+	}
+}
+
+func TestLoadFile_RejectsUnknownProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(path, []byte("lr: x\nprofile: kodachrome\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := style.LoadFile(path); err == nil {
+		t.Error("expected an error for an unknown camera profile")
+	}
+
+	path = filepath.Join(t.TempDir(), "chrome.yaml")
+	if err := os.WriteFile(path, []byte("lr: x\nprofile: classic-chrome\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := style.LoadFile(path); err != nil {
+		t.Errorf("a Camera Matching profile should be accepted: %v", err)
+	}
+}
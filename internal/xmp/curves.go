@@ -0,0 +1,75 @@
+package xmp
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// curveNames are the point curve properties, in the order Lightroom writes
+// them. Each is a CameraRawSettings field of the same name.
+var curveNames = []string{"ToneCurvePV2012", "ToneCurvePV2012Red", "ToneCurvePV2012Green", "ToneCurvePV2012Blue"}
+
+// pointCurve is a point curve element: an rdf:Seq of "input, output" points.
+type pointCurve struct {
+	XMLName xml.Name
+	Points  []string `xml:"rdf:Seq>rdf:li"`
+}
+
+// newPointCurves converts the point curves of settings to their elements.
+// Points are sorted by input and rounded to whole levels from 0 to 255;
+// curves with fewer than two points are left out.
+func newPointCurves(settings CameraRawSettings) []pointCurve {
+	var curves []pointCurve
+	v := reflect.ValueOf(settings)
+	for _, name := range curveNames {
+		points := v.FieldByName(name).Interface().([][]float64)
+		var out [][2]int
+		for _, p := range points {
+			if len(p) >= 2 {
+				out = append(out, [2]int{level(p[0]), level(p[1])})
+			}
+		}
+		if len(out) < 2 {
+			continue
+		}
+		slices.SortStableFunc(out, func(a, b [2]int) int { return cmp.Compare(a[0], b[0]) })
+
+		curve := pointCurve{XMLName: xml.Name{Local: "crs:" + name}}
+		for _, p := range out {
+			curve.Points = append(curve.Points, fmt.Sprintf("%d, %d", p[0], p[1]))
+		}
+		curves = append(curves, curve)
+	}
+	return curves
+}
+
+func level(v float64) int {
+	return int(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+// isCurve reports whether name is a point curve property.
+func isCurve(name string) bool {
+	return slices.Contains(curveNames, name)
+}
+
+// appendPoint adds an "input, output" point read from XMP to the named curve.
+// Malformed points are skipped.
+func appendPoint(settings *CameraRawSettings, name, value string) {
+	x, y, ok := strings.Cut(value, ",")
+	if !ok {
+		return
+	}
+	xf, err1 := strconv.ParseFloat(strings.TrimSpace(x), 64)
+	yf, err2 := strconv.ParseFloat(strings.TrimSpace(y), 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	f := reflect.ValueOf(settings).Elem().FieldByName(name)
+	f.Set(reflect.Append(f, reflect.ValueOf([]float64{xf, yf})))
+}
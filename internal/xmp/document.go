@@ -0,0 +1,402 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"sidelight/pkg/models"
+)
+
+// Document is an XMP document kept as an element tree, so a sidecar can be
+// rewritten without losing what other applications stored in it: ratings,
+// labels, keywords, history and any namespace SideLight does not know.
+// Element and attribute names keep the prefixes of the source. Comments and
+// processing instructions outside the root element are kept; those inside
+// it, and whitespace between elements, are not.
+type Document struct {
+	prolog []xml.Token // <?xml?>, <?xpacket begin?> and comments before the root
+	root   *node
+	epilog []xml.Token // <?xpacket end?> and anything else after the root
+}
+
+// node is an element of a Document. Text is the character data of an
+// element without children.
+type node struct {
+	name     xml.Name // Space holds the prefix, not the namespace URI
+	attr     []xml.Attr
+	children []*node
+	text     string
+}
+
+// ParseDocument reads an XMP document into a Document.
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	var stack []*node
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse XMP: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attr: slices.Clone(t.Attr)}
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			case doc.root == nil:
+				doc.root = n
+			default:
+				return nil, fmt.Errorf("failed to parse XMP: more than one root element")
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].name != t.Name {
+				return nil, fmt.Errorf("failed to parse XMP: unexpected </%s>", qualified(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		case xml.ProcInst, xml.Comment, xml.Directive:
+			if doc.root == nil {
+				doc.prolog = append(doc.prolog, xml.CopyToken(t))
+			} else if len(stack) == 0 {
+				doc.epilog = append(doc.epilog, xml.CopyToken(t))
+			}
+		}
+	}
+
+	if doc.root == nil {
+		return nil, errors.New("failed to parse XMP: no root element")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("failed to parse XMP: <%s> is not closed", qualified(stack[len(stack)-1].name))
+	}
+	return doc, nil
+}
+
+// Bytes serializes the document, indenting elements by two spaces.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, t := range d.prolog {
+		writeToken(&buf, t)
+		buf.WriteByte('\n')
+	}
+	d.root.write(&buf, 0)
+	for _, t := range d.epilog {
+		writeToken(&buf, t)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Merge writes settings and dc into the document. Only the crs properties
+// every grade sets and the ones the new settings carry are replaced, so a
+// camera profile, grain or crop made in Lightroom survives a grade without
+// one; crs:Look is dropped when a camera profile is written. dc:title and
+// dc:description are only filled in when the document has none, so titles
+// and captions entered by hand are kept, and new keywords are added to
+// dc:subject. Everything else is left untouched.
+func (d *Document) Merge(settings CameraRawSettings, dc DublinCore) error {
+	data, err := MarshalWithDC(settings, dc)
+	if err != nil {
+		return err
+	}
+	fresh, err := ParseDocument(data)
+	if err != nil {
+		return err
+	}
+	src := fresh.root.children[0].children[0] // x:xmpmeta > rdf:RDF > rdf:Description
+
+	rdfPath := d.find(NsRdf, "RDF")
+	if rdfPath == nil {
+		return errors.New("failed to merge XMP: no rdf:RDF element")
+	}
+	rdf := rdfPath[len(rdfPath)-1]
+
+	target := d.settingsDescription(rdfPath)
+	if target == nil {
+		// Nothing to merge into: add the settings as a description of their own.
+		if prefix, ok := lookupPrefix(rdfPath, NsRdf); !ok || prefix != "rdf" {
+			src.attr = append(src.attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: "rdf"}, Value: NsRdf})
+		}
+		rdf.children = append(rdf.children, src)
+		return nil
+	}
+
+	path := append(slices.Clone(rdfPath), target)
+	prefixes := map[string]string{"rdf": declare(path, NsRdf, "rdf")}
+	prefixes["crs"] = declare(path, NsCrs, "crs")
+	crs := prefixes["crs"]
+	if src.hasChild("dc", "") {
+		prefixes["dc"] = declare(path, NsDc, "dc")
+	}
+	src.rename(prefixes)
+	owns := ownedProperties(settings, src, crs)
+
+	// Attributes: drop the owned crs ones, then add the new values.
+	target.attr = slices.DeleteFunc(target.attr, func(a xml.Attr) bool {
+		return a.Name.Space == crs && owns(a.Name.Local)
+	})
+	for _, a := range src.attr {
+		if a.Name.Space == crs {
+			target.attr = append(target.attr, a)
+		}
+	}
+
+	// Elements: the same for crs properties; dc properties are merged.
+	target.children = slices.DeleteFunc(target.children, func(n *node) bool {
+		return n.name.Space == crs && owns(n.name.Local)
+	})
+	for _, n := range src.children {
+		if n.name.Space == crs {
+			target.children = append(target.children, n)
+			continue
+		}
+		if n.name.Local == "subject" {
+			target.mergeKeywords(n)
+			continue
+		}
+		if !target.hasChild(n.name.Space, n.name.Local) {
+			target.children = append(target.children, n)
+		}
+	}
+	return nil
+}
+
+// Merge updates the existing XMP document data with settings and dc, as
+// Document.Merge does. Without existing data it is MarshalWithDC.
+func Merge(existing []byte, settings CameraRawSettings, dc DublinCore) ([]byte, error) {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return MarshalWithDC(settings, dc)
+	}
+	doc, err := ParseDocument(existing)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Merge(settings, dc); err != nil {
+		return nil, err
+	}
+	return doc.Bytes(), nil
+}
+
+// ownedProperties returns whether a merge replaces a crs property: when it
+// is one every grade sets, zero or not, or when src, the new description
+// using prefix crs, carries it. The crop properties are replaced as a group,
+// and crs:Look goes with the camera profile it was chosen for.
+func ownedProperties(settings CameraRawSettings, src *node, crs string) func(string) bool {
+	carried := gradedProperties()
+	for _, a := range src.attr {
+		if a.Name.Space == crs {
+			carried[a.Name.Local] = true
+		}
+	}
+	for _, n := range src.children {
+		if n.name.Space == crs {
+			carried[n.name.Local] = true
+		}
+	}
+	hasCrop := settings.HasCrop != ""
+
+	return func(name string) bool {
+		switch {
+		case name == "HasCrop" || strings.HasPrefix(name, "Crop"):
+			return hasCrop
+		case name == "Look":
+			return carried[name] || settings.CameraProfile != ""
+		}
+		return carried[name]
+	}
+}
+
+// gradedProperties are the required fields of models.GradingParams, which
+// CameraRawSettings mirrors by name.
+func gradedProperties() map[string]bool {
+	t := reflect.TypeOf(models.GradingParams{})
+	graded := make(map[string]bool)
+	for _, spec := range models.Fields(t) {
+		if !spec.Optional {
+			graded[t.FieldByIndex(spec.Index).Name] = true
+		}
+	}
+	return graded
+}
+
+// find returns the path from the root to the first element with the given
+// namespace and local name, or nil.
+func (d *Document) find(space, local string) []*node {
+	var walk func(path []*node) []*node
+	walk = func(path []*node) []*node {
+		n := path[len(path)-1]
+		if ns, ok := lookupNamespace(path, n.name.Space); ok && ns == space && n.name.Local == local {
+			return path
+		}
+		for _, c := range n.children {
+			if found := walk(append(slices.Clone(path), c)); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return walk([]*node{d.root})
+}
+
+// settingsDescription picks the rdf:Description the settings go in: the
+// first that binds the crs namespace, else the first one.
+func (d *Document) settingsDescription(rdfPath []*node) *node {
+	var first *node
+	for _, c := range rdfPath[len(rdfPath)-1].children {
+		path := append(slices.Clone(rdfPath), c)
+		if ns, ok := lookupNamespace(path, c.name.Space); !ok || ns != NsRdf || c.name.Local != "Description" {
+			continue
+		}
+		if first == nil {
+			first = c
+		}
+		for _, a := range c.attr {
+			if a.Name.Space == "xmlns" && a.Value == NsCrs {
+				return c
+			}
+		}
+	}
+	return first
+}
+
+// mergeKeywords adds the keywords of subject that the description's own
+// dc:subject does not have yet, or adds subject if there is none.
+func (n *node) mergeKeywords(subject *node) {
+	var bag *node
+	for _, c := range n.children {
+		if c.name == subject.name && len(c.children) > 0 {
+			bag = c.children[0]
+		}
+	}
+	if bag == nil {
+		n.children = append(n.children, subject)
+		return
+	}
+	for _, li := range subject.children[0].children {
+		if !slices.ContainsFunc(bag.children, func(old *node) bool { return strings.TrimSpace(old.text) == li.text }) {
+			bag.children = append(bag.children, li)
+		}
+	}
+}
+
+// hasChild reports whether n has a child element with the given prefix and,
+// unless local is empty, local name.
+func (n *node) hasChild(prefix, local string) bool {
+	return slices.ContainsFunc(n.children, func(c *node) bool {
+		return c.name.Space == prefix && (local == "" || c.name.Local == local)
+	})
+}
+
+// rename replaces the prefixes of n and its descendants by prefixes[old].
+func (n *node) rename(prefixes map[string]string) {
+	if p, ok := prefixes[n.name.Space]; ok {
+		n.name.Space = p
+	}
+	for i, a := range n.attr {
+		if p, ok := prefixes[a.Name.Space]; ok {
+			n.attr[i].Name.Space = p
+		}
+	}
+	for _, c := range n.children {
+		c.rename(prefixes)
+	}
+}
+
+// declare returns the prefix bound to uri at the end of path, binding it to
+// prefix on the last element if it is not declared yet.
+func declare(path []*node, uri, prefix string) string {
+	if p, ok := lookupPrefix(path, uri); ok {
+		return p
+	}
+	n := path[len(path)-1]
+	n.attr = append(n.attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: uri})
+	return prefix
+}
+
+// lookupPrefix returns the prefix bound to uri in the scope of the last
+// element of path.
+func lookupPrefix(path []*node, uri string) (string, bool) {
+	for i := len(path) - 1; i >= 0; i-- {
+		for _, a := range path[i].attr {
+			if a.Name.Space == "xmlns" && a.Value == uri {
+				return a.Name.Local, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookupNamespace returns the namespace URI bound to prefix in the scope of
+// the last element of path.
+func lookupNamespace(path []*node, prefix string) (string, bool) {
+	for i := len(path) - 1; i >= 0; i-- {
+		for _, a := range path[i].attr {
+			if (prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) ||
+				(prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") {
+				return a.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (n *node) write(buf *bytes.Buffer, depth int) {
+	indent := strings.Repeat("  ", depth)
+	buf.WriteString(indent + "<" + qualified(n.name))
+	for _, a := range n.attr {
+		buf.WriteString(" " + qualified(a.Name) + `="`)
+		xml.EscapeText(buf, []byte(a.Value))
+		buf.WriteByte('"')
+	}
+
+	switch {
+	case len(n.children) > 0:
+		buf.WriteString(">\n")
+		for _, c := range n.children {
+			c.write(buf, depth+1)
+		}
+		buf.WriteString(indent)
+	case strings.TrimSpace(n.text) != "":
+		buf.WriteByte('>')
+		xml.EscapeText(buf, []byte(n.text))
+	default:
+		buf.WriteString("/>\n")
+		return
+	}
+	buf.WriteString("</" + qualified(n.name) + ">\n")
+}
+
+func writeToken(buf *bytes.Buffer, tok xml.Token) {
+	switch t := tok.(type) {
+	case xml.ProcInst:
+		fmt.Fprintf(buf, "<?%s %s?>", t.Target, t.Inst)
+	case xml.Comment:
+		fmt.Fprintf(buf, "<!--%s-->", t)
+	case xml.Directive:
+		fmt.Fprintf(buf, "<!%s>", t)
+	}
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
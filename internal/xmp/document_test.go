@@ -0,0 +1,189 @@
+package xmp_test
+
+import (
+	"strings"
+	"testing"
+
+	"sidelight/internal/xmp"
+)
+
+// lightroomSidecar is a sidecar as Lightroom writes it, with a rating,
+// keywords, history, a crop and a mask of its own.
+const lightroomSidecar = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="Adobe XMP Core 7.0-c000">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"
+    xmlns:stEvt="http://ns.adobe.com/xap/1.0/sType/ResourceEvent#"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/"
+   xmp:Rating="4"
+   xmp:Label="Red"
+   crs:Version="15.4"
+   crs:RawFileName="DSC0001.ARW"
+   crs:Exposure2012="+0.30"
+   crs:Contrast2012="+12"
+   crs:HasCrop="True"
+   crs:CropTop="0.1"
+   crs:CropBottom="0.9">
+   <xmpMM:History>
+    <rdf:Seq>
+     <rdf:li stEvt:action="saved" stEvt:softwareAgent="Adobe Photoshop Lightroom Classic 12.0"/>
+    </rdf:Seq>
+   </xmpMM:History>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>travel</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+   <crs:MaskGroupBasedCorrections>
+    <rdf:Seq>
+     <rdf:li crs:What="Correction"/>
+    </rdf:Seq>
+   </crs:MaskGroupBasedCorrections>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestMerge_KeepsForeignProperties(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.Exposure2012 = 1.5
+
+	data, err := xmp.Merge([]byte(lightroomSidecar), settings, xmp.DublinCore{Title: "Old Town", Subject: []string{"travel", "alley"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		`<?xpacket begin=`, `<?xpacket end="w"?>`, `x:xmptk="Adobe XMP Core 7.0-c000"`,
+		`xmp:Rating="4"`, `xmp:Label="Red"`, `crs:Version="15.4"`, `crs:RawFileName="DSC0001.ARW"`,
+		`stEvt:action="saved"`, `crs:HasCrop="True"`, `crs:CropTop="0.1"`, `<crs:MaskGroupBasedCorrections>`,
+		`crs:Exposure2012="1.5"`, `<rdf:li>travel</rdf:li>`, `<rdf:li>alley</rdf:li>`, `<dc:title>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("merged sidecar should contain %s", want)
+		}
+	}
+	if strings.Contains(out, "Contrast2012") || strings.Contains(out, `"+0.30"`) {
+		t.Error("the previous grade should be replaced")
+	}
+	if strings.Count(out, "<rdf:li>travel</rdf:li>") != 1 {
+		t.Error("keywords should not be duplicated")
+	}
+
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Exposure2012 != 1.5 || got.CropTop != 0.1 {
+		t.Errorf("unexpected settings after merge %+v", got)
+	}
+}
+
+func TestMerge_ReplacesOwnCropAndMasks(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.HasCrop, settings.CropTop, settings.CropBottom, settings.CropRight = "True", 0.2, 0.8, 1
+
+	data, err := xmp.Merge([]byte(lightroomSidecar), settings, xmp.DublinCore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if !strings.Contains(out, `crs:CropTop="0.2"`) || strings.Contains(out, `crs:CropTop="0.1"`) {
+		t.Error("a new crop should replace the old one")
+	}
+	if !strings.Contains(out, `<rdf:li>travel</rdf:li>`) {
+		t.Error("keywords should be kept without a description")
+	}
+}
+
+func TestMerge_ProfileAndLook(t *testing.T) {
+	existing := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/" crs:CameraProfile="Camera Standard" crs:GrainAmount="25">
+ <crs:Look>
+  <rdf:Description crs:Name="Vintage 07" crs:Amount="0.6"/>
+ </crs:Look>
+</rdf:Description></rdf:RDF></x:xmpmeta>`
+
+	settings := xmp.NewCameraRawSettings()
+	settings.Exposure2012 = 0.5
+	data, err := xmp.Merge([]byte(existing), settings, xmp.DublinCore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{`crs:CameraProfile="Camera Standard"`, `crs:GrainAmount="25"`, `crs:Name="Vintage 07"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("a grade without a profile should keep %s, got:\n%s", want, out)
+		}
+	}
+
+	settings.CameraProfile = "Camera Vivid"
+	data, err = xmp.Merge([]byte(existing), settings, xmp.DublinCore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = string(data)
+	if !strings.Contains(out, `crs:CameraProfile="Camera Vivid"`) || strings.Contains(out, "Vintage 07") {
+		t.Errorf("a new profile should replace the old one and its look, got:\n%s", out)
+	}
+}
+
+func TestMerge_KeepsTitleAndCaption(t *testing.T) {
+	existing := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+ <dc:title><rdf:Alt><rdf:li xml:lang="x-default">My title</rdf:li></rdf:Alt></dc:title>
+</rdf:Description></rdf:RDF></x:xmpmeta>`
+
+	data, err := xmp.Merge([]byte(existing), xmp.NewCameraRawSettings(), xmp.DublinCore{Title: "Old Town", Description: "A quiet alley"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if !strings.Contains(out, "My title") || strings.Contains(out, "Old Town") {
+		t.Errorf("an existing title should not be overwritten, got:\n%s", out)
+	}
+	if !strings.Contains(out, "A quiet alley") {
+		t.Errorf("a missing description should be filled in, got:\n%s", out)
+	}
+}
+
+func TestMerge_OtherPrefixes(t *testing.T) {
+	existing := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><r:RDF xmlns:r="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<r:Description xmlns:cr="http://ns.adobe.com/camera-raw-settings/1.0/" cr:Exposure2012="0.3" cr:Custom="kept"/>
+</r:RDF></x:xmpmeta>`
+	settings := xmp.NewCameraRawSettings()
+	settings.ToneCurvePV2012 = [][]float64{{0, 0}, {255, 255}}
+
+	data, err := xmp.Merge([]byte(existing), settings, xmp.DublinCore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{`cr:Custom="kept"`, "<cr:ToneCurvePV2012>", "<r:Seq>", `cr:ProcessVersion="11.0"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("merged sidecar should contain %s, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "crs:") || strings.Contains(out, `"0.3"`) {
+		t.Errorf("properties should use the document's prefixes, got:\n%s", out)
+	}
+}
+
+func TestMerge_Errors(t *testing.T) {
+	for name, existing := range map[string]string{
+		"malformed": `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF>`,
+		"no rdf":    `<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`,
+	} {
+		if _, err := xmp.Merge([]byte(existing), xmp.NewCameraRawSettings(), xmp.DublinCore{}); err == nil {
+			t.Errorf("%s: expected an error rather than overwriting the sidecar", name)
+		}
+	}
+
+	data, err := xmp.Merge(nil, xmp.NewCameraRawSettings(), xmp.DublinCore{})
+	if err != nil || !strings.Contains(string(data), `crs:HasSettings="True"`) {
+		t.Errorf("without a sidecar a new one should be written, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package xmp
+
+import (
+	"crypto/md5"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"sidelight/pkg/models"
+)
+
+// Lightroom's AI mask subtypes for Mask/Image.
+var imageMaskSubType = map[string]string{
+	models.MaskSubject: "1",
+	models.MaskSky:     "2",
+}
+
+// maskGroup is crs:MaskGroupBasedCorrections, Lightroom's list of masked
+// local corrections. Each correction is an rdf:Description holding its Local*
+// amounts and, in crs:CorrectionMasks, the masks that select its area.
+type maskGroup struct {
+	Items []correctionItem `xml:"rdf:Seq>rdf:li"`
+}
+
+type correctionItem struct {
+	Description correction `xml:"rdf:Description"`
+}
+
+type correction struct {
+	What              string   `xml:"crs:What,attr"`
+	CorrectionAmount  string   `xml:"crs:CorrectionAmount,attr"`
+	CorrectionActive  string   `xml:"crs:CorrectionActive,attr"`
+	CorrectionName    string   `xml:"crs:CorrectionName,attr,omitempty"`
+	CorrectionSyncID  string   `xml:"crs:CorrectionSyncID,attr"`
+	LocalExposure2012 string   `xml:"crs:LocalExposure2012,attr"`
+	LocalContrast2012 string   `xml:"crs:LocalContrast2012,attr"`
+	LocalTemperature  string   `xml:"crs:LocalTemperature,attr"`
+	Masks             maskList `xml:"crs:CorrectionMasks"`
+}
+
+type maskList struct {
+	Items []maskItem `xml:"rdf:Seq>rdf:li"`
+}
+
+type maskItem struct {
+	Description mask `xml:"rdf:Description"`
+}
+
+type mask struct {
+	What         string `xml:"crs:What,attr"`
+	MaskValue    string `xml:"crs:MaskValue,attr"`
+	MaskBlend    string `xml:"crs:MaskBlendMode,attr"`
+	MaskInverted string `xml:"crs:MaskInverted,attr"`
+	MaskSyncID   string `xml:"crs:MaskSyncID,attr"`
+
+	// Mask/Gradient
+	ZeroX string `xml:"crs:ZeroX,attr,omitempty"`
+	ZeroY string `xml:"crs:ZeroY,attr,omitempty"`
+	FullX string `xml:"crs:FullX,attr,omitempty"`
+	FullY string `xml:"crs:FullY,attr,omitempty"`
+
+	// Mask/CircularGradient
+	Top       string `xml:"crs:Top,attr,omitempty"`
+	Left      string `xml:"crs:Left,attr,omitempty"`
+	Bottom    string `xml:"crs:Bottom,attr,omitempty"`
+	Right     string `xml:"crs:Right,attr,omitempty"`
+	Angle     string `xml:"crs:Angle,attr,omitempty"`
+	Midpoint  string `xml:"crs:Midpoint,attr,omitempty"`
+	Roundness string `xml:"crs:Roundness,attr,omitempty"`
+	Feather   string `xml:"crs:Feather,attr,omitempty"`
+	Flipped   string `xml:"crs:Flipped,attr,omitempty"`
+
+	// Mask/Image
+	MaskSubType string `xml:"crs:MaskSubType,attr,omitempty"`
+}
+
+// newMaskGroup converts local adjustments to Lightroom corrections. Lightroom
+// stores local exposure, contrast and temperature as -1 to 1, where exposure
+// 1 is +4 stops. Adjustments of an unknown type are skipped; nil is returned
+// when none remain.
+func newMaskGroup(adjustments []models.LocalAdjustment) *maskGroup {
+	group := &maskGroup{}
+	for i, a := range adjustments {
+		m, ok := newMask(a)
+		if !ok {
+			continue
+		}
+		m.MaskValue, m.MaskBlend, m.MaskInverted = "1", "0", "false"
+		m.MaskSyncID = syncID("mask", i)
+
+		group.Items = append(group.Items, correctionItem{Description: correction{
+			What:              "Correction",
+			CorrectionAmount:  "1",
+			CorrectionActive:  "true",
+			CorrectionName:    a.Name,
+			CorrectionSyncID:  syncID("correction", i),
+			LocalExposure2012: formatFloat(a.Exposure / 4),
+			LocalContrast2012: formatFloat(float64(a.Contrast) / 100),
+			LocalTemperature:  formatFloat(float64(a.Temperature) / 100),
+			Masks:             maskList{Items: []maskItem{{Description: m}}},
+		}})
+	}
+	if len(group.Items) == 0 {
+		return nil
+	}
+	return group
+}
+
+// newMask builds the mask geometry of an adjustment.
+func newMask(a models.LocalAdjustment) (mask, bool) {
+	kind := strings.ToLower(strings.TrimSpace(a.Type))
+	switch kind {
+	case models.MaskLinear:
+		return mask{
+			What:  "Mask/Gradient",
+			FullX: formatFloat(a.X1), FullY: formatFloat(a.Y1),
+			ZeroX: formatFloat(a.X2), ZeroY: formatFloat(a.Y2),
+		}, true
+	case models.MaskRadial:
+		return mask{
+			What: "Mask/CircularGradient",
+			Left: formatFloat(min(a.X1, a.X2)), Right: formatFloat(max(a.X1, a.X2)),
+			Top: formatFloat(min(a.Y1, a.Y2)), Bottom: formatFloat(max(a.Y1, a.Y2)),
+			Angle: "0", Midpoint: "50", Roundness: "0",
+			Feather: strconv.Itoa(a.Feather), Flipped: "false",
+		}, true
+	case models.MaskSky, models.MaskSubject:
+		return mask{What: "Mask/Image", MaskSubType: imageMaskSubType[kind]}, true
+	default:
+		return mask{}, false
+	}
+}
+
+// syncID derives a stable GUID-like identifier, so the same grade always
+// produces the same sidecar.
+func syncID(kind string, i int) string {
+	return fmt.Sprintf("%X", md5.Sum([]byte(fmt.Sprintf("sidelight/%s/%d", kind, i))))
+}
+
+// formatFloat writes v with at most four decimals.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,66 @@
+package xmp_test
+
+import (
+	"strings"
+	"testing"
+
+	"sidelight/internal/xmp"
+	"sidelight/pkg/models"
+)
+
+func TestMarshal_LocalCorrections(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.Exposure2012 = 0.5
+	settings.Corrections = []models.LocalAdjustment{
+		{Type: models.MaskLinear, Name: "Darken sky", X1: 0.5, Y1: 0, X2: 0.5, Y2: 0.4, Exposure: -1, Temperature: -10},
+		{Type: models.MaskRadial, X1: 0.3, Y1: 0.2, X2: 0.6, Y2: 0.7, Feather: 60, Exposure: 0.4, Contrast: 15},
+		{Type: models.MaskSubject, Exposure: 0.2},
+		{Type: "brush", Exposure: 1},
+	}
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		"<crs:MaskGroupBasedCorrections>",
+		`crs:CorrectionName="Darken sky"`,
+		`crs:LocalExposure2012="-0.25"`,
+		`crs:LocalTemperature="-0.1"`,
+		`crs:What="Mask/Gradient"`,
+		`crs:ZeroX="0.5" crs:ZeroY="0.4" crs:FullX="0.5" crs:FullY="0"`,
+		`crs:What="Mask/CircularGradient"`,
+		`crs:Top="0.2" crs:Left="0.3" crs:Bottom="0.7" crs:Right="0.6"`,
+		`crs:LocalContrast2012="0.15"`,
+		`crs:What="Mask/Image"`,
+		`crs:MaskSubType="1"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+	if n := strings.Count(out, `crs:What="Correction"`); n != 3 {
+		t.Errorf("expected 3 corrections, unknown mask types skipped, got %d", n)
+	}
+
+	// Local correction attributes must not leak into the global settings.
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Exposure2012 != 0.5 {
+		t.Errorf("global exposure changed to %v", got.Exposure2012)
+	}
+}
+
+func TestMarshal_NoCorrections(t *testing.T) {
+	data, err := xmp.Marshal(xmp.NewCameraRawSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "MaskGroupBasedCorrections") {
+		t.Error("no mask group expected without local adjustments")
+	}
+}
@@ -0,0 +1,208 @@
+package xmp
+
+import (
+	"sort"
+	"strings"
+)
+
+// Profile names used by styles and the model. The adobe-* profiles work for
+// every raw file; the others are Camera Matching profiles that exist only for
+// some makes and otherwise fall back to the closest Adobe profile.
+const (
+	ProfileAdobeStandard   = "adobe-standard"
+	ProfileAdobeColor      = "adobe-color"
+	ProfileAdobePortrait   = "adobe-portrait"
+	ProfileAdobeLandscape  = "adobe-landscape"
+	ProfileAdobeVivid      = "adobe-vivid"
+	ProfileAdobeNeutral    = "adobe-neutral"
+	ProfileAdobeMonochrome = "adobe-monochrome"
+)
+
+// adobeLook is an Adobe Raw profile. Lightroom writes it as a crs:Look on
+// top of the Adobe Standard camera profile and finds it by its UUID.
+type adobeLook struct {
+	Name string
+	UUID string // empty while unknown; the look is then written by name only
+}
+
+// adobeLooks are the Adobe Raw profiles.
+var adobeLooks = map[string]adobeLook{
+	ProfileAdobeColor:      {"Adobe Color", "B952C231111CD8E0ECCF14B86BAA7077"},
+	ProfileAdobePortrait:   {Name: "Adobe Portrait"},
+	ProfileAdobeLandscape:  {Name: "Adobe Landscape"},
+	ProfileAdobeVivid:      {Name: "Adobe Vivid"},
+	ProfileAdobeNeutral:    {Name: "Adobe Neutral"},
+	ProfileAdobeMonochrome: {Name: "Adobe Monochrome"},
+}
+
+// lookUUID returns the UUID of the Adobe Raw profile with the given name.
+func lookUUID(name string) string {
+	for _, l := range adobeLooks {
+		if l.Name == name {
+			return l.UUID
+		}
+	}
+	return ""
+}
+
+// profileFallbacks maps each Camera Matching profile to the Adobe profile
+// used for cameras that lack it.
+var profileFallbacks = map[string]string{
+	"camera-standard":   ProfileAdobeColor,
+	"camera-faithful":   ProfileAdobeColor,
+	"camera-portrait":   ProfileAdobePortrait,
+	"camera-landscape":  ProfileAdobeLandscape,
+	"camera-vivid":      ProfileAdobeVivid,
+	"camera-neutral":    ProfileAdobeNeutral,
+	"camera-monochrome": ProfileAdobeMonochrome,
+	"provia":            ProfileAdobeColor,
+	"velvia":            ProfileAdobeVivid,
+	"astia":             ProfileAdobePortrait,
+	"classic-chrome":    ProfileAdobeNeutral,
+	"classic-neg":       ProfileAdobeNeutral,
+	"eterna":            ProfileAdobeNeutral,
+	"pro-neg-hi":        ProfileAdobePortrait,
+	"pro-neg-std":       ProfileAdobeNeutral,
+	"acros":             ProfileAdobeMonochrome,
+}
+
+// cameraProfiles lists the Camera Matching profiles Lightroom ships per
+// make, keyed by the first word of the lower-cased EXIF make.
+var cameraProfiles = map[string]map[string]string{
+	"canon": {
+		"camera-standard":   "Camera Standard",
+		"camera-faithful":   "Camera Faithful",
+		"camera-portrait":   "Camera Portrait",
+		"camera-landscape":  "Camera Landscape",
+		"camera-neutral":    "Camera Neutral",
+		"camera-monochrome": "Camera Monochrome",
+	},
+	"nikon": {
+		"camera-standard":   "Camera Standard",
+		"camera-portrait":   "Camera Portrait",
+		"camera-landscape":  "Camera Landscape",
+		"camera-vivid":      "Camera Vivid",
+		"camera-neutral":    "Camera Neutral",
+		"camera-monochrome": "Camera Monochrome",
+	},
+	"sony": {
+		"camera-standard":  "Camera Standard",
+		"camera-portrait":  "Camera Portrait",
+		"camera-landscape": "Camera Landscape",
+		"camera-vivid":     "Camera Vivid",
+		"camera-neutral":   "Camera Neutral",
+	},
+	"fujifilm": {
+		"camera-standard":   "Camera PROVIA/Standard",
+		"camera-vivid":      "Camera Velvia/Vivid",
+		"camera-portrait":   "Camera ASTIA/Soft",
+		"camera-monochrome": "Camera MONOCHROME",
+		"provia":            "Camera PROVIA/Standard",
+		"velvia":            "Camera Velvia/Vivid",
+		"astia":             "Camera ASTIA/Soft",
+		"classic-chrome":    "Camera CLASSIC CHROME",
+		"classic-neg":       "Camera CLASSIC Neg.",
+		"eterna":            "Camera ETERNA/Cinema",
+		"pro-neg-hi":        "Camera PRO Neg. Hi",
+		"pro-neg-std":       "Camera PRO Neg. Std",
+		"acros":             "Camera ACROS",
+	},
+	"olympus": olympusProfiles,
+	"om":      olympusProfiles, // OM Digital Solutions
+	"panasonic": {
+		"camera-standard":   "Camera STANDARD",
+		"camera-portrait":   "Camera PORTRAIT",
+		"camera-landscape":  "Camera SCENERY",
+		"camera-vivid":      "Camera VIVID",
+		"camera-neutral":    "Camera NATURAL",
+		"camera-monochrome": "Camera MONOCHROME",
+	},
+}
+
+var olympusProfiles = map[string]string{
+	"camera-standard":   "Camera Natural",
+	"camera-portrait":   "Camera Portrait",
+	"camera-vivid":      "Camera Vivid",
+	"camera-neutral":    "Camera Muted",
+	"camera-monochrome": "Camera Monotone",
+}
+
+// makeKey reduces an EXIF make such as "NIKON CORPORATION" to its table key.
+func makeKey(cameraMake string) string {
+	fields := strings.Fields(strings.ToLower(cameraMake))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// normalizeProfile turns "Classic Chrome" or "classic_chrome" into "classic-chrome".
+func normalizeProfile(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(name)
+}
+
+// ResolveProfile returns the crs:CameraProfile and crs:Look for a profile
+// name on a camera of the given make. Besides the names above, the profile
+// and look names Lightroom writes ("Camera CLASSIC CHROME", "Adobe Color")
+// are accepted, so a sidecar read back resolves to itself. ok is false for
+// names that are unknown.
+func ResolveProfile(cameraMake, name string) (cameraProfile, look string, ok bool) {
+	key := normalizeProfile(name)
+	if key == "" {
+		return "", "", false
+	}
+	for id, profile := range cameraProfiles[makeKey(cameraMake)] {
+		if key == id || key == normalizeProfile(profile) {
+			return profile, "", true
+		}
+	}
+	if key == ProfileAdobeStandard {
+		return "Adobe Standard", "", true
+	}
+	for id, l := range adobeLooks {
+		if key == id || key == normalizeProfile(l.Name) {
+			return "Adobe Standard", l.Name, true
+		}
+	}
+	if fallback, ok := profileFallbacks[key]; ok {
+		return ResolveProfile(cameraMake, fallback)
+	}
+	return "", "", false
+}
+
+// ProfileName is the inverse of ResolveProfile for a sidecar: it returns the
+// profile name of a crs:CameraProfile and crs:Look pair. Lightroom writes an
+// Adobe Raw profile as its look on top of Adobe Standard, so that pair names
+// the look; any other look is a creative one and the camera profile is kept.
+func ProfileName(cameraProfile, look string) string {
+	if cameraProfile != "" && cameraProfile != "Adobe Standard" {
+		return cameraProfile
+	}
+	for _, l := range adobeLooks {
+		if look == l.Name {
+			return look
+		}
+	}
+	return cameraProfile
+}
+
+// IsProfile reports whether name is a profile name styles can use.
+func IsProfile(name string) bool {
+	_, _, ok := ResolveProfile("", name)
+	return ok
+}
+
+// ProfileNames returns the profile names available for a make: the Adobe
+// profiles plus the make's own Camera Matching profiles, sorted.
+func ProfileNames(cameraMake string) []string {
+	names := []string{ProfileAdobeStandard}
+	for id := range adobeLooks {
+		names = append(names, id)
+	}
+	for id := range cameraProfiles[makeKey(cameraMake)] {
+		names = append(names, id)
+	}
+	sort.Strings(names)
+	return names
+}
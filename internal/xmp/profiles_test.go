@@ -0,0 +1,53 @@
+package xmp_test
+
+import (
+	"testing"
+
+	"sidelight/internal/xmp"
+)
+
+func TestResolveProfile(t *testing.T) {
+	tests := []struct {
+		make, name            string
+		wantProfile, wantLook string
+	}{
+		{"FUJIFILM", "classic-chrome", "Camera CLASSIC CHROME", ""},
+		{"SONY", "classic-chrome", "Adobe Standard", "Adobe Neutral"},
+		{"NIKON CORPORATION", "camera-vivid", "Camera Vivid", ""},
+		{"Canon", "camera-vivid", "Adobe Standard", "Adobe Vivid"},
+		{"Canon", "Adobe Portrait", "Adobe Standard", "Adobe Portrait"},
+		{"", "adobe-color", "Adobe Standard", "Adobe Color"},
+		{"OM Digital Solutions", "camera-neutral", "Camera Muted", ""},
+		{"", "adobe-standard", "Adobe Standard", ""},
+		// names as Lightroom writes them, read back from a sidecar
+		{"FUJIFILM", "Camera CLASSIC CHROME", "Camera CLASSIC CHROME", ""},
+	}
+	for _, tt := range tests {
+		profile, look, ok := xmp.ResolveProfile(tt.make, tt.name)
+		if !ok || profile != tt.wantProfile || look != tt.wantLook {
+			t.Errorf("ResolveProfile(%q, %q) = %q, %q, %v; want %q, %q", tt.make, tt.name, profile, look, ok, tt.wantProfile, tt.wantLook)
+		}
+	}
+
+	for _, name := range []string{"", "kodachrome"} {
+		if _, _, ok := xmp.ResolveProfile("FUJIFILM", name); ok {
+			t.Errorf("%q should not resolve", name)
+		}
+	}
+}
+
+func TestProfileName(t *testing.T) {
+	tests := []struct{ profile, look, want string }{
+		{"Adobe Standard", "Adobe Portrait", "Adobe Portrait"},
+		{"Adobe Standard", "", "Adobe Standard"},
+		{"Adobe Color", "", "Adobe Color"},
+		// a creative look must not replace the camera profile
+		{"Camera CLASSIC CHROME", "Vintage 07", "Camera CLASSIC CHROME"},
+		{"Adobe Standard", "Vintage 07", "Adobe Standard"},
+	}
+	for _, tt := range tests {
+		if got := xmp.ProfileName(tt.profile, tt.look); got != tt.want {
+			t.Errorf("ProfileName(%q, %q) = %q, want %q", tt.profile, tt.look, got, tt.want)
+		}
+	}
+}
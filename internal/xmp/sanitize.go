@@ -0,0 +1,202 @@
+package xmp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sidelight/pkg/models"
+)
+
+// ErrOutOfRange is returned by Sanitize under PolicyReject.
+var ErrOutOfRange = errors.New("parameter out of range")
+
+// Policy decides what Sanitize does with a value outside its limits.
+type Policy string
+
+const (
+	PolicyClamp  Policy = "clamp"  // pull the value to the nearest limit and carry on
+	PolicyReject Policy = "reject" // fail, so no sidecar is written
+)
+
+// SafetyProfile caps how far a parameter may move from neutral, on top of
+// its documented range. Keys are the JSON names of models.GradingParams.
+// Saturation is never capped, so black and white styles keep working.
+type SafetyProfile map[string]float64
+
+// SafetyProfiles are the named profiles selectable with --safety.
+var SafetyProfiles = map[string]SafetyProfile{
+	"off": nil,
+	"standard": withHSL(SafetyProfile{
+		"exposure": 3, "contrast": 75, "whites": 75, "blacks": 75,
+		"texture": 60, "clarity": 60, "dehaze": 50, "vibrance": 75,
+		"temperature": 4000, "tint": 60, "vignette_amount": 60,
+		"split_shadow_saturation": 60, "split_highlight_saturation": 60,
+		"color_grade_midtone_sat": 60, "color_grade_global_sat": 40,
+	}, 60, 80),
+	"strict": withHSL(SafetyProfile{
+		"exposure": 1.5, "contrast": 40, "highlights": 80, "shadows": 80, "whites": 50, "blacks": 50,
+		"texture": 40, "clarity": 40, "dehaze": 30, "vibrance": 50,
+		"temperature": 2500, "tint": 30, "vignette_amount": 40,
+		"split_shadow_saturation": 40, "split_highlight_saturation": 40,
+		"color_grade_midtone_sat": 40, "color_grade_global_sat": 25,
+	}, 30, 50),
+}
+
+// DefaultSafetyProfile is the profile used unless another is selected.
+const DefaultSafetyProfile = "standard"
+
+// withHSL adds the per-color HSL caps to a profile.
+func withHSL(p SafetyProfile, hue, satLum float64) SafetyProfile {
+	for _, color := range []string{"red", "orange", "yellow", "green", "aqua", "blue", "purple", "magenta"} {
+		p["hue_"+color] = hue
+		p["saturation_"+color] = satLum
+		p["luminance_"+color] = satLum
+	}
+	return p
+}
+
+// SanitizeOptions configures Sanitize.
+type SanitizeOptions struct {
+	Policy  Policy
+	Profile SafetyProfile // nil checks only the documented ranges
+
+	// AsShotTemperature is the white balance of the file in Kelvin, from
+	// models.Metadata.ColorTemperature. The temperature cap of Profile is
+	// measured from it; when it is 0 (unknown) only the documented range
+	// applies to the temperature.
+	AsShotTemperature int
+}
+
+// DefaultSanitizeOptions clamps to the standard safety profile.
+func DefaultSanitizeOptions() SanitizeOptions {
+	return SanitizeOptions{Policy: PolicyClamp, Profile: SafetyProfiles[DefaultSafetyProfile]}
+}
+
+// Violation is one value found outside its limits.
+type Violation struct {
+	Field    string  // JSON path, e.g. "exposure"
+	Value    float64 // as returned by the model
+	Min, Max float64 // effective limits
+	Result   float64 // value written instead, under PolicyClamp
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %g outside [%g, %g], set to %g", v.Field, v.Value, v.Min, v.Max, v.Result)
+}
+
+// Sanitize checks every numeric value of params against the range documented
+// in the schema tags of models.GradingParams, narrowed by opts.Profile, so no
+// model reply can produce a destructive XMP. Under PolicyClamp out-of-range
+// values are pulled to the nearest limit, except the white balance
+// temperature, which falls back to As Shot: a clamped 2000K or 50000K is no
+// safer than the original. A temperature of 0 is As Shot and always valid.
+// Under PolicyReject an ErrOutOfRange is returned
+// instead. The violations found are returned in field order.
+func Sanitize(params *models.GradingParams, opts SanitizeOptions) ([]Violation, error) {
+	var found []Violation
+	sanitizeStruct(reflect.ValueOf(params).Elem(), "", opts, &found)
+
+	if len(found) > 0 && opts.Policy == PolicyReject {
+		msgs := make([]string, len(found))
+		for i, v := range found {
+			msgs[i] = v.String()
+		}
+		return found, fmt.Errorf("%w: %s", ErrOutOfRange, strings.Join(msgs, "; "))
+	}
+	return found, nil
+}
+
+// sanitizeStruct checks the fields of a parameter struct.
+func sanitizeStruct(v reflect.Value, prefix string, opts SanitizeOptions, found *[]Violation) {
+	for _, spec := range models.Fields(v.Type()) {
+		path := spec.Name
+		if prefix != "" {
+			path = prefix + "." + spec.Name
+		}
+		sanitizeValue(v.FieldByIndex(spec.Index), path, spec, opts, found)
+	}
+}
+
+// sanitizeValue checks one field; ranges apply to every numeric leaf of a
+// slice, as in the response schema.
+func sanitizeValue(f reflect.Value, path string, spec models.FieldSpec, opts SanitizeOptions, found *[]Violation) {
+	switch f.Kind() {
+	case reflect.Ptr:
+		if !f.IsNil() {
+			sanitizeValue(f.Elem(), path, spec, opts, found)
+		}
+		return
+	case reflect.Struct:
+		sanitizeStruct(f, path, opts, found)
+		return
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < f.Len(); i++ {
+			sanitizeValue(f.Index(i), fmt.Sprintf("%s[%d]", path, i), spec, opts, found)
+		}
+		return
+	}
+
+	lo, hi, ok := limits(path, spec, opts)
+	if !ok {
+		return
+	}
+
+	var value float64
+	switch f.Kind() {
+	case reflect.Float32, reflect.Float64:
+		value = f.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(f.Int())
+	default:
+		return
+	}
+	if (value >= lo && value <= hi) || (path == "temperature" && value == 0) {
+		return
+	}
+
+	result := math.Max(lo, math.Min(hi, value))
+	if math.IsNaN(value) || path == "temperature" {
+		result = 0 // neutral; As Shot for the temperature
+	}
+	if f.CanFloat() {
+		f.SetFloat(result)
+	} else {
+		f.SetInt(int64(math.Round(result)))
+	}
+	*found = append(*found, Violation{Field: path, Value: value, Min: lo, Max: hi, Result: result})
+}
+
+// limits returns the allowed range of a field: its documented range
+// intersected with the profile's deviation from neutral. Neutral is 0, or
+// the as-shot value for the temperature, which is not capped when that is
+// unknown.
+func limits(path string, spec models.FieldSpec, opts SanitizeOptions) (lo, hi float64, ok bool) {
+	lo, hi = math.Inf(-1), math.Inf(1)
+	if spec.HasRange {
+		lo, hi, ok = spec.Min, spec.Max, true
+	}
+	dev, capped := opts.Profile[path]
+	var n float64
+	if path == "temperature" {
+		n = float64(opts.AsShotTemperature)
+		capped = capped && n > 0
+	}
+	if capped {
+		lo, hi, ok = math.Max(lo, n-dev), math.Min(hi, n+dev), true
+	}
+	return lo, hi, ok
+}
+
+// SafetyProfileNames returns the names of the built-in profiles, sorted.
+func SafetyProfileNames() []string {
+	names := make([]string, 0, len(SafetyProfiles))
+	for name := range SafetyProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
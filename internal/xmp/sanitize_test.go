@@ -0,0 +1,87 @@
+package xmp_test
+
+import (
+	"errors"
+	"testing"
+
+	"sidelight/internal/xmp"
+	"sidelight/pkg/models"
+)
+
+func TestSanitize_DocumentedRanges(t *testing.T) {
+	params := &models.GradingParams{
+		Exposure2012:         7,
+		Temperature:          50001,
+		SplitToningShadowHue: 720,
+		Saturation:           -100,
+	}
+
+	fixes, err := xmp.Sanitize(params, xmp.SanitizeOptions{Policy: xmp.PolicyClamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 3 {
+		t.Fatalf("expected 3 fixes, got %v", fixes)
+	}
+	if params.Exposure2012 != 5 || params.SplitToningShadowHue != 360 {
+		t.Errorf("values should be clamped to their range, got %+v", params)
+	}
+	if params.Temperature != 0 {
+		t.Errorf("an impossible temperature should fall back to As Shot, got %d", params.Temperature)
+	}
+	if params.Saturation != -100 {
+		t.Error("values in range must not change")
+	}
+}
+
+func TestSanitize_SafetyProfile(t *testing.T) {
+	params := &models.GradingParams{Exposure2012: -2.5, Temperature: 9000, Saturation: -100}
+
+	fixes, err := xmp.Sanitize(params, xmp.SanitizeOptions{Policy: xmp.PolicyClamp, Profile: xmp.SafetyProfiles["strict"], AsShotTemperature: 5500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Exposure2012 != -1.5 {
+		t.Errorf("strict profile should cap exposure at 1.5 stops, got %v", params.Exposure2012)
+	}
+	if params.Temperature != 0 || len(fixes) != 2 {
+		t.Errorf("9000K is beyond the strict deviation from 5500K as shot, got %d (%v)", params.Temperature, fixes)
+	}
+	if params.Saturation != -100 {
+		t.Error("saturation is not capped, black and white must survive")
+	}
+}
+
+func TestSanitize_Reject(t *testing.T) {
+	params := &models.GradingParams{Exposure2012: 1, Temperature: 5500, Contrast2012: 150}
+
+	_, err := xmp.Sanitize(params, xmp.SanitizeOptions{Policy: xmp.PolicyReject})
+	if !errors.Is(err, xmp.ErrOutOfRange) {
+		t.Fatalf("expected ErrOutOfRange, got %v", err)
+	}
+
+	ok := &models.GradingParams{Exposure2012: 1, Temperature: 5500}
+	if fixes, err := xmp.Sanitize(ok, xmp.SanitizeOptions{Policy: xmp.PolicyReject, Profile: xmp.SafetyProfiles["standard"]}); err != nil || len(fixes) != 0 {
+		t.Errorf("a sane grade should pass, got %v %v", fixes, err)
+	}
+}
+
+func TestSanitize_TemperatureAsShot(t *testing.T) {
+	strict := xmp.SanitizeOptions{Policy: xmp.PolicyReject, Profile: xmp.SafetyProfiles["strict"]}
+
+	tungsten := strict
+	tungsten.AsShotTemperature = 3000
+	if fixes, err := xmp.Sanitize(&models.GradingParams{Temperature: 2800}, tungsten); err != nil || len(fixes) != 0 {
+		t.Errorf("2800K is close to 3000K as shot, got %v %v", fixes, err)
+	}
+	if _, err := xmp.Sanitize(&models.GradingParams{Temperature: 7000}, tungsten); !errors.Is(err, xmp.ErrOutOfRange) {
+		t.Errorf("7000K is beyond the strict deviation from 3000K as shot, got %v", err)
+	}
+
+	if fixes, err := xmp.Sanitize(&models.GradingParams{Temperature: 2800}, strict); err != nil || len(fixes) != 0 {
+		t.Errorf("without an as-shot temperature only the documented range applies, got %v %v", fixes, err)
+	}
+	if fixes, err := xmp.Sanitize(&models.GradingParams{Temperature: 0}, tungsten); err != nil || len(fixes) != 0 {
+		t.Errorf("0 is As Shot and must pass, got %v %v", fixes, err)
+	}
+}
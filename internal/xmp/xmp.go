@@ -1,8 +1,16 @@
 package xmp
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"sidelight/pkg/models"
 )
 
 const (
@@ -10,6 +18,7 @@ const (
 	NsX   = "adobe:ns:meta/"
 	NsRdf = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
 	NsCrs = "http://ns.adobe.com/camera-raw-settings/1.0/"
+	NsDc  = "http://purl.org/dc/elements/1.1/"
 
 	// Standard Header
 	XmpHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
@@ -22,6 +31,7 @@ type CameraRawSettings struct {
 	HasSettings    string `xml:"crs:HasSettings,attr,omitempty"`
 	AlreadyApplied string `xml:"crs:AlreadyApplied,attr,omitempty"`
 	CameraProfile  string `xml:"crs:CameraProfile,attr,omitempty"`
+	Look           string `xml:"-"` // Adobe Raw profile name, written as crs:Look
 
 	// Basic Tone
 	Exposure2012   float64 `xml:"crs:Exposure2012,attr,omitempty"`
@@ -51,7 +61,22 @@ type CameraRawSettings struct {
 	ColorNoiseReduction int `xml:"crs:ColorNoiseReduction,attr,omitempty"`
 
 	// Vignette
-	PostCropVignetteAmount int `xml:"crs:PostCropVignetteAmount,attr,omitempty"`
+	PostCropVignetteAmount     int `xml:"crs:PostCropVignetteAmount,attr,omitempty"`
+	PostCropVignetteMidpoint   int `xml:"crs:PostCropVignetteMidpoint,attr,omitempty"`
+	PostCropVignetteFeather    int `xml:"crs:PostCropVignetteFeather,attr,omitempty"`
+	PostCropVignetteRoundness  int `xml:"crs:PostCropVignetteRoundness,attr,omitempty"`
+	PostCropVignetteStyle      int `xml:"crs:PostCropVignetteStyle,attr,omitempty"`
+	PostCropVignetteHighlights int `xml:"crs:PostCropVignetteHighlights,attr,omitempty"`
+
+	// Grain
+	GrainAmount    int `xml:"crs:GrainAmount,attr,omitempty"`
+	GrainSize      int `xml:"crs:GrainSize,attr,omitempty"`
+	GrainFrequency int `xml:"crs:GrainFrequency,attr,omitempty"`
+
+	// Lens Corrections
+	LensProfileEnable int    `xml:"crs:LensProfileEnable,attr,omitempty"`
+	LensProfileSetup  string `xml:"crs:LensProfileSetup,attr,omitempty"` // "LensDefaults" picks the profile from the EXIF lens
+	AutoLateralCA     int    `xml:"crs:AutoLateralCA,attr,omitempty"`
 
 	// HSL - Hue
 	HueAdjustmentRed     int `xml:"crs:HueAdjustmentRed,attr,omitempty"`
@@ -83,12 +108,69 @@ type CameraRawSettings struct {
 	LuminanceAdjustmentPurple  int `xml:"crs:LuminanceAdjustmentPurple,attr,omitempty"`
 	LuminanceAdjustmentMagenta int `xml:"crs:LuminanceAdjustmentMagenta,attr,omitempty"`
 
+	// Black & White. The gray mixer only takes effect with ConvertToGrayscale "True".
+	ConvertToGrayscale string `xml:"crs:ConvertToGrayscale,attr,omitempty"`
+	GrayMixerRed       int    `xml:"crs:GrayMixerRed,attr,omitempty"`
+	GrayMixerOrange    int    `xml:"crs:GrayMixerOrange,attr,omitempty"`
+	GrayMixerYellow    int    `xml:"crs:GrayMixerYellow,attr,omitempty"`
+	GrayMixerGreen     int    `xml:"crs:GrayMixerGreen,attr,omitempty"`
+	GrayMixerAqua      int    `xml:"crs:GrayMixerAqua,attr,omitempty"`
+	GrayMixerBlue      int    `xml:"crs:GrayMixerBlue,attr,omitempty"`
+	GrayMixerPurple    int    `xml:"crs:GrayMixerPurple,attr,omitempty"`
+	GrayMixerMagenta   int    `xml:"crs:GrayMixerMagenta,attr,omitempty"`
+
 	// Split Toning
 	SplitToningShadowHue           int `xml:"crs:SplitToningShadowHue,attr,omitempty"`
 	SplitToningShadowSaturation    int `xml:"crs:SplitToningShadowSaturation,attr,omitempty"`
 	SplitToningHighlightHue        int `xml:"crs:SplitToningHighlightHue,attr,omitempty"`
 	SplitToningHighlightSaturation int `xml:"crs:SplitToningHighlightSaturation,attr,omitempty"`
 	SplitToningBalance             int `xml:"crs:SplitToningBalance,attr,omitempty"`
+
+	// Color Grading. The shadow and highlight wheels' hue and saturation are
+	// the split toning attributes above, their balance SplitToningBalance.
+	ColorGradeShadowLum    int `xml:"crs:ColorGradeShadowLum,attr,omitempty"`
+	ColorGradeMidtoneHue   int `xml:"crs:ColorGradeMidtoneHue,attr,omitempty"`
+	ColorGradeMidtoneSat   int `xml:"crs:ColorGradeMidtoneSat,attr,omitempty"`
+	ColorGradeMidtoneLum   int `xml:"crs:ColorGradeMidtoneLum,attr,omitempty"`
+	ColorGradeHighlightLum int `xml:"crs:ColorGradeHighlightLum,attr,omitempty"`
+	ColorGradeGlobalHue    int `xml:"crs:ColorGradeGlobalHue,attr,omitempty"`
+	ColorGradeGlobalSat    int `xml:"crs:ColorGradeGlobalSat,attr,omitempty"`
+	ColorGradeGlobalLum    int `xml:"crs:ColorGradeGlobalLum,attr,omitempty"`
+	ColorGradeBlending     int `xml:"crs:ColorGradeBlending,attr,omitempty"`
+
+	// Parametric Tone Curve
+	ParametricShadows    int `xml:"crs:ParametricShadows,attr,omitempty"`
+	ParametricDarks      int `xml:"crs:ParametricDarks,attr,omitempty"`
+	ParametricLights     int `xml:"crs:ParametricLights,attr,omitempty"`
+	ParametricHighlights int `xml:"crs:ParametricHighlights,attr,omitempty"`
+
+	// Point Curves, as [input, output] pairs from 0 to 255. They are written
+	// as rdf:Seq elements rather than attributes.
+	ToneCurveName2012    string      `xml:"crs:ToneCurveName2012,attr,omitempty"`
+	ToneCurvePV2012      [][]float64 `xml:"-"`
+	ToneCurvePV2012Red   [][]float64 `xml:"-"`
+	ToneCurvePV2012Green [][]float64 `xml:"-"`
+	ToneCurvePV2012Blue  [][]float64 `xml:"-"`
+
+	// Crop & Straighten, in fractions of the image as stored
+	HasCrop             string  `xml:"crs:HasCrop,attr,omitempty"`
+	CropTop             float64 `xml:"crs:CropTop,attr,omitempty"`
+	CropLeft            float64 `xml:"crs:CropLeft,attr,omitempty"`
+	CropBottom          float64 `xml:"crs:CropBottom,attr,omitempty"`
+	CropRight           float64 `xml:"crs:CropRight,attr,omitempty"`
+	CropAngle           float64 `xml:"crs:CropAngle,attr,omitempty"`
+	CropConstrainToWarp int     `xml:"crs:CropConstrainToWarp,attr,omitempty"`
+
+	// Local corrections, written as crs:MaskGroupBasedCorrections
+	Corrections []models.LocalAdjustment `xml:"-"`
+}
+
+// DublinCore holds the descriptive dc: properties written next to the
+// settings. Lightroom shows them as title, caption and keywords.
+type DublinCore struct {
+	Title       string
+	Description string
+	Subject     []string
 }
 
 // rdfDescription represents the inner content of the RDF.
@@ -96,7 +178,56 @@ type rdfDescription struct {
 	XMLName  xml.Name `xml:"rdf:Description"`
 	About    string   `xml:"rdf:about,attr"`
 	XmlnsCrs string   `xml:"xmlns:crs,attr"`
+	XmlnsDc  string   `xml:"xmlns:dc,attr,omitempty"`
 	CameraRawSettings
+
+	Look       *look `xml:"crs:Look,omitempty"`
+	ToneCurves []pointCurve
+	Masks      *maskGroup `xml:"crs:MaskGroupBasedCorrections,omitempty"`
+
+	Title       *langAlt `xml:"dc:title,omitempty"`
+	Description *langAlt `xml:"dc:description,omitempty"`
+	Subject     *rdfBag  `xml:"dc:subject,omitempty"`
+}
+
+// look is crs:Look, the profile applied on top of the camera profile.
+type look struct {
+	Description lookDescription `xml:"rdf:Description"`
+}
+
+type lookDescription struct {
+	Name   string `xml:"crs:Name,attr"`
+	Amount string `xml:"crs:Amount,attr"`
+	UUID   string `xml:"crs:UUID,attr,omitempty"`
+}
+
+func newLook(name string) *look {
+	if name == "" {
+		return nil
+	}
+	return &look{Description: lookDescription{Name: name, Amount: "1", UUID: lookUUID(name)}}
+}
+
+// langAlt is an rdf:Alt language alternative with a single default entry.
+type langAlt struct {
+	Items []langItem `xml:"rdf:Alt>rdf:li"`
+}
+
+type langItem struct {
+	Lang  string `xml:"xml:lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+// rdfBag is an unordered rdf:Bag of text values.
+type rdfBag struct {
+	Items []string `xml:"rdf:Bag>rdf:li"`
+}
+
+func newLangAlt(value string) *langAlt {
+	if value == "" {
+		return nil
+	}
+	return &langAlt{Items: []langItem{{Lang: "x-default", Value: value}}}
 }
 
 // rdfRDF represents the <rdf:RDF> container.
@@ -125,17 +256,36 @@ func NewCameraRawSettings() CameraRawSettings {
 
 // Marshal generates the full XMP byte slice for the given settings.
 func Marshal(settings CameraRawSettings) ([]byte, error) {
+	return MarshalWithDC(settings, DublinCore{})
+}
+
+// MarshalWithDC generates the XMP for the given settings plus descriptive
+// metadata. Empty dc properties are omitted.
+func MarshalWithDC(settings CameraRawSettings, dc DublinCore) ([]byte, error) {
+	desc := &rdfDescription{
+		About:             "",
+		XmlnsCrs:          NsCrs,
+		CameraRawSettings: settings,
+		Look:              newLook(settings.Look),
+		ToneCurves:        newPointCurves(settings),
+		Masks:             newMaskGroup(settings.Corrections),
+		Title:             newLangAlt(dc.Title),
+		Description:       newLangAlt(dc.Description),
+	}
+	if len(dc.Subject) > 0 {
+		desc.Subject = &rdfBag{Items: dc.Subject}
+	}
+	if desc.Title != nil || desc.Description != nil || desc.Subject != nil {
+		desc.XmlnsDc = NsDc
+	}
+
 	// Wrap the settings in the XMP envelope
 	xmp := &xmpMeta{
 		XmlnsX: NsX,
 		XmpTk:  "SideLight", // Tool name
 		RDF: &rdfRDF{
-			XmlnsRdf: NsRdf,
-			Description: &rdfDescription{
-				About:             "",
-				XmlnsCrs:          NsCrs,
-				CameraRawSettings: settings,
-			},
+			XmlnsRdf:    NsRdf,
+			Description: desc,
 		},
 	}
 
@@ -147,3 +297,121 @@ func Marshal(settings CameraRawSettings) ([]byte, error) {
 	// Combine header and XML body
 	return append([]byte(XmpHeader), output...), nil
 }
+
+// Unmarshal reads the Camera Raw settings from an XMP document, such as a
+// sidecar written by Marshal or by Lightroom. Settings are taken from crs
+// attributes of any rdf:Description, from simple crs child elements, from
+// the point curves and from the name of crs:Look; other structured
+// properties are ignored. ParseDocument keeps the whole document instead.
+func Unmarshal(data []byte) (CameraRawSettings, error) {
+	var settings CameraRawSettings
+	fields := crsFields()
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var current string // crs element whose text is being read
+	var curve string   // point curve whose rdf:Seq is being read
+	inPoint := false
+	inLook := false
+	found := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return settings, fmt.Errorf("failed to parse XMP: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = ""
+			if inLook {
+				// Only the look's name is kept, not the settings it bundles
+				if t.Name.Space == NsRdf && t.Name.Local == "Description" && settings.Look == "" {
+					for _, attr := range t.Attr {
+						if attr.Name.Space == NsCrs && attr.Name.Local == "Name" {
+							settings.Look = attr.Value
+						}
+					}
+				}
+				continue
+			}
+			if t.Name.Space == NsRdf && t.Name.Local == "Description" {
+				found = true
+				for _, attr := range t.Attr {
+					if attr.Name.Space == NsCrs {
+						setField(&settings, fields, attr.Name.Local, attr.Value)
+					}
+				}
+			} else if t.Name.Space == NsCrs && t.Name.Local == "Look" {
+				inLook = true
+			} else if t.Name.Space == NsCrs && isCurve(t.Name.Local) {
+				curve = t.Name.Local
+			} else if t.Name.Space == NsCrs {
+				current = t.Name.Local
+			} else if curve != "" && t.Name.Space == NsRdf && t.Name.Local == "li" {
+				inPoint = true
+			}
+		case xml.CharData:
+			if inLook {
+				continue
+			}
+			if inPoint {
+				appendPoint(&settings, curve, strings.TrimSpace(string(t)))
+			} else if current != "" {
+				setField(&settings, fields, current, strings.TrimSpace(string(t)))
+			}
+		case xml.EndElement:
+			current = ""
+			inPoint = false
+			if t.Name.Space == NsCrs && t.Name.Local == curve {
+				curve = ""
+			}
+			if t.Name.Space == NsCrs && t.Name.Local == "Look" {
+				inLook = false
+			}
+		}
+	}
+
+	if !found {
+		return settings, fmt.Errorf("no rdf:Description found in XMP")
+	}
+	return settings, nil
+}
+
+// crsFields maps crs property names to CameraRawSettings field indexes.
+func crsFields() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(CameraRawSettings{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("xml"), ",")[0]
+		if local, ok := strings.CutPrefix(name, "crs:"); ok {
+			fields[local] = i
+		}
+	}
+	return fields
+}
+
+// setField assigns a textual XMP value to the named field. Values Lightroom
+// writes with an explicit sign ("+0.50") are accepted; malformed ones are skipped.
+func setField(settings *CameraRawSettings, fields map[string]int, name, value string) {
+	i, ok := fields[name]
+	if !ok || value == "" {
+		return
+	}
+	f := reflect.ValueOf(settings).Elem().Field(i)
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int:
+		if n, err := strconv.Atoi(value); err == nil {
+			f.SetInt(int64(n))
+		} else if n, err := strconv.ParseFloat(value, 64); err == nil {
+			f.SetInt(int64(math.Round(n)))
+		}
+	case reflect.Float64:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			f.SetFloat(n)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package xmp_test
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -96,3 +97,229 @@ func TestMarshalDefaults(t *testing.T) {
 		t.Error("Expected default ProcessVersion")
 	}
 }
+
+func TestMarshalWithDC(t *testing.T) {
+	dc := xmp.DublinCore{
+		Title:       "Harbor at Dawn",
+		Description: "Fishing boats moored in a calm harbor at sunrise.",
+		Subject:     []string{"harbor", "boats", "sunrise"},
+	}
+	data, err := xmp.MarshalWithDC(xmp.NewCameraRawSettings(), dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlStr := string(data)
+	for _, want := range []string{
+		`xmlns:dc="http://purl.org/dc/elements/1.1/"`,
+		`<rdf:li xml:lang="x-default">Harbor at Dawn</rdf:li>`,
+		`<dc:description>`,
+		`<rdf:li>boats</rdf:li>`,
+	} {
+		if !strings.Contains(xmlStr, want) {
+			t.Errorf("Expected XMP to contain %s", want)
+		}
+	}
+	if _, err := xmp.Unmarshal(data); err != nil {
+		t.Errorf("dc properties must not break reading the settings back: %v", err)
+	}
+
+	plain, _ := xmp.Marshal(xmp.NewCameraRawSettings())
+	if strings.Contains(string(plain), "dc:") {
+		t.Error("Expected no dc properties without a description")
+	}
+}
+
+func TestUnmarshal_RoundTrip(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.Exposure2012 = 0.75
+	settings.Contrast2012 = -15
+	settings.Temperature = 6200
+	settings.SplitToningShadowHue = 210
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("round trip mismatch:\n got  %+v\n want %+v", got, settings)
+	}
+}
+
+func TestUnmarshal_LightroomSidecar(t *testing.T) {
+	// Lightroom writes signed values and some properties as child elements.
+	data := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:camera="http://ns.adobe.com/camera-raw-settings/1.0/"
+    camera:Exposure2012="+0.50" camera:Shadows2012="+25" camera:Blacks2012="-8">
+   <camera:Vibrance>+12</camera:Vibrance>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`)
+
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Exposure2012 != 0.5 || got.Shadows2012 != 25 || got.Blacks2012 != -8 || got.Vibrance != 12 {
+		t.Errorf("unexpected settings %+v", got)
+	}
+
+	if _, err := xmp.Unmarshal([]byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"/>")); err == nil {
+		t.Error("expected an error for XMP without rdf:Description")
+	}
+}
+
+func TestMarshal_Crop(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.HasCrop = "True"
+	settings.CropTop, settings.CropLeft, settings.CropBottom, settings.CropRight = 0.1, 0.05, 0.9, 0.95
+	settings.CropAngle = -1.25
+	settings.CropConstrainToWarp = 1
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`crs:HasCrop="True"`, `crs:CropTop="0.1"`, `crs:CropRight="0.95"`, `crs:CropAngle="-1.25"`, `crs:CropConstrainToWarp="1"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("round trip mismatch:\n got  %+v\n want %+v", got, settings)
+	}
+}
+
+func TestMarshal_CurvesAndColorGrade(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.ColorGradeMidtoneHue = 200
+	settings.ColorGradeMidtoneSat = 12
+	settings.ColorGradeShadowLum = -10
+	settings.ColorGradeBlending = 60
+	settings.ParametricDarks = -15
+	settings.ToneCurveName2012 = "Custom"
+	settings.ToneCurvePV2012 = [][]float64{{0, 10}, {128, 128}, {255, 245}}
+	settings.ToneCurvePV2012Blue = [][]float64{{255, 240}, {0, 20}}
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		`crs:ColorGradeMidtoneHue="200"`, `crs:ColorGradeBlending="60"`, `crs:ParametricDarks="-15"`,
+		"<crs:ToneCurvePV2012>", "<rdf:li>128, 128</rdf:li>", "<crs:ToneCurvePV2012Blue>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+	if strings.Contains(out, "ToneCurvePV2012Red") {
+		t.Error("unset curves should be left out")
+	}
+
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Points are written sorted by input.
+	settings.ToneCurvePV2012Blue = [][]float64{{0, 20}, {255, 240}}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("round trip mismatch:\n got  %+v\n want %+v", got, settings)
+	}
+}
+
+func TestMarshal_GrainVignetteLens(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.GrainAmount, settings.GrainSize, settings.GrainFrequency = 30, 35, 60
+	settings.PostCropVignetteAmount, settings.PostCropVignetteFeather, settings.PostCropVignetteStyle = -20, 70, 1
+	settings.LensProfileEnable, settings.LensProfileSetup, settings.AutoLateralCA = 1, "LensDefaults", 1
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`crs:GrainAmount="30"`, `crs:GrainSize="35"`, `crs:GrainFrequency="60"`,
+		`crs:PostCropVignetteFeather="70"`, `crs:PostCropVignetteStyle="1"`,
+		`crs:LensProfileEnable="1"`, `crs:LensProfileSetup="LensDefaults"`, `crs:AutoLateralCA="1"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+	if strings.Contains(string(data), "PostCropVignetteMidpoint") {
+		t.Error("unset vignette midpoint should keep Lightroom's default")
+	}
+}
+
+func TestMarshal_Grayscale(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.ConvertToGrayscale = "True"
+	settings.GrayMixerBlue, settings.GrayMixerOrange = -40, 25
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`crs:ConvertToGrayscale="True"`, `crs:GrayMixerBlue="-40"`, `crs:GrayMixerOrange="25"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+
+	got, err := xmp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ConvertToGrayscale != "True" || got.GrayMixerBlue != -40 || got.GrayMixerOrange != 25 {
+		t.Errorf("grayscale settings should survive a round trip, got %+v", got)
+	}
+}
+
+func TestMarshal_Look(t *testing.T) {
+	settings := xmp.NewCameraRawSettings()
+	settings.CameraProfile, settings.Look = "Adobe Standard", "Adobe Color"
+
+	data, err := xmp.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lightroom finds the profile by its UUID, not its name.
+	for _, want := range []string{`crs:CameraProfile="Adobe Standard"`, "<crs:Look>", `crs:Name="Adobe Color"`, `crs:Amount="1"`, `crs:UUID="B952C231111CD8E0ECCF14B86BAA7077"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("output should contain %s", want)
+		}
+	}
+
+	// Lightroom bundles the look's own settings; they must not leak into the grade.
+	lightroom := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/" crs:CameraProfile="Adobe Standard" crs:Exposure2012="+0.30">
+ <crs:Look>
+  <rdf:Description crs:Name="Adobe Landscape" crs:Amount="1">
+   <crs:Parameters>
+    <rdf:Description crs:ConvertToGrayscale="False" crs:Exposure2012="-1.00">
+     <crs:ToneCurvePV2012><rdf:Seq><rdf:li>0, 10</rdf:li><rdf:li>255, 245</rdf:li></rdf:Seq></crs:ToneCurvePV2012>
+    </rdf:Description>
+   </crs:Parameters>
+  </rdf:Description>
+ </crs:Look>
+</rdf:Description></rdf:RDF></x:xmpmeta>`
+	got, err := xmp.Unmarshal([]byte(lightroom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Look != "Adobe Landscape" || got.CameraProfile != "Adobe Standard" || got.Exposure2012 != 0.3 || got.ConvertToGrayscale != "" || got.ToneCurvePV2012 != nil {
+		t.Errorf("expected only the look name, got %+v", got)
+	}
+}
@@ -1,5 +1,7 @@
 package models
 
+import "strings"
+
 // Metadata holds technical details extracted from the image.
 type Metadata struct {
 	Make         string `json:"make"`
@@ -9,154 +11,337 @@ type Metadata struct {
 	Aperture     string `json:"aperture"` // Stored as string to handle "f/1.8" etc.
 	ShutterSpeed string `json:"shutter_speed"`
 	FocalLength  string `json:"focal_length"`
-	DateTime     string `json:"date_time"`
+	DateTime     string `json:"date_time"`              // local time of capture
+	TimeZone     string `json:"time_zone,omitempty"`    // UTC offset of DateTime, e.g. "+02:00"
+	Orientation  int    `json:"orientation,omitempty"`  // EXIF orientation (1-8) of the source file
+	ImageWidth   int    `json:"image_width,omitempty"`  // pixels as stored, before orientation
+	ImageHeight  int    `json:"image_height,omitempty"` // pixels as stored, before orientation
+
+	ExposureCompensation string `json:"exposure_compensation,omitempty"` // e.g. "-2/3"
+	MeteringMode         string `json:"metering_mode,omitempty"`
+	FlashFired           *bool  `json:"flash_fired,omitempty"`       // nil if the file does not say
+	WhiteBalance         string `json:"white_balance,omitempty"`     // camera setting, e.g. "Auto", "Daylight"
+	ColorTemperature     int    `json:"color_temperature,omitempty"` // Kelvin as shot, 0 if unknown
+	PictureStyle         string `json:"picture_style,omitempty"`     // picture style, film simulation or creative style
+
+	GPS *GPSPosition `json:"gps,omitempty"`
+}
+
+// GPSPosition is where a photo was taken, in signed decimal degrees.
+type GPSPosition struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 // GradingParams defines the color grading parameters returned by the AI.
 type GradingParams struct {
+	// Camera profile the grade starts from (raw files only)
+	CameraProfile string `json:"camera_profile" schema:"optional" desc:"base profile from the list in the prompt, empty keeps the default"`
+
 	// Basic Tone
-	Exposure2012   float64 `json:"exposure"`
-	Contrast2012   int     `json:"contrast"`
-	Highlights2012 int     `json:"highlights"`
-	Shadows2012    int     `json:"shadows"`
-	Whites2012     int     `json:"whites"`
-	Blacks2012     int     `json:"blacks"`
+	Exposure2012   float64 `json:"exposure" schema:"min=-5,max=5"`
+	Contrast2012   int     `json:"contrast" schema:"min=-100,max=100"`
+	Highlights2012 int     `json:"highlights" schema:"min=-100,max=100"`
+	Shadows2012    int     `json:"shadows" schema:"min=-100,max=100"`
+	Whites2012     int     `json:"whites" schema:"min=-100,max=100"`
+	Blacks2012     int     `json:"blacks" schema:"min=-100,max=100"`
 
 	// Presence
-	Texture     int `json:"texture"`
-	Clarity2012 int `json:"clarity"`
-	Dehaze      int `json:"dehaze"`
-	Vibrance    int `json:"vibrance"`
-	Saturation  int `json:"saturation"`
+	Texture     int `json:"texture" schema:"min=-100,max=100"`
+	Clarity2012 int `json:"clarity" schema:"min=-100,max=100"`
+	Dehaze      int `json:"dehaze" schema:"min=-100,max=100"`
+	Vibrance    int `json:"vibrance" schema:"min=-100,max=100"`
+	Saturation  int `json:"saturation" schema:"min=-100,max=100"`
 
 	// White Balance
-	Temperature int `json:"temperature"`
-	Tint        int `json:"tint"`
+	Temperature int `json:"temperature" schema:"min=2000,max=50000" desc:"white balance in Kelvin"`
+	Tint        int `json:"tint" schema:"min=-150,max=150" desc:"negative is green, positive is magenta"`
 
 	// Detail & Noise
-	Sharpness           int `json:"sharpness"`
-	LuminanceSmoothing  int `json:"luminance_noise_reduction"`
-	ColorNoiseReduction int `json:"color_noise_reduction"`
+	Sharpness           int `json:"sharpness" schema:"min=0,max=150"`
+	LuminanceSmoothing  int `json:"luminance_noise_reduction" schema:"min=0,max=100"`
+	ColorNoiseReduction int `json:"color_noise_reduction" schema:"min=0,max=100"`
 
 	// Vignette
-	PostCropVignetteAmount int `json:"vignette_amount"`
+	PostCropVignetteAmount     int `json:"vignette_amount" schema:"min=-100,max=0" desc:"negative values darken corners"`
+	PostCropVignetteMidpoint   int `json:"vignette_midpoint" schema:"min=0,max=100,optional" desc:"0 keeps the default of 50"`
+	PostCropVignetteFeather    int `json:"vignette_feather" schema:"min=0,max=100,optional" desc:"0 keeps the default of 50"`
+	PostCropVignetteRoundness  int `json:"vignette_roundness" schema:"min=-100,max=100,optional"`
+	PostCropVignetteStyle      int `json:"vignette_style" schema:"min=0,max=3,optional" desc:"1 highlight priority, 2 color priority, 3 paint overlay; 0 keeps the default of 1"`
+	PostCropVignetteHighlights int `json:"vignette_highlights" schema:"min=0,max=100,optional" desc:"highlight protection of the highlight priority style"`
+
+	// Grain
+	GrainAmount    int `json:"grain_amount" schema:"min=0,max=100,optional"`
+	GrainSize      int `json:"grain_size" schema:"min=0,max=100,optional" desc:"0 keeps the default of 25"`
+	GrainFrequency int `json:"grain_roughness" schema:"min=0,max=100,optional" desc:"0 keeps the default of 50"`
+
+	// Lens Corrections
+	LensProfileEnable int `json:"lens_profile_enable" schema:"min=0,max=1,optional" desc:"1 corrects distortion and vignetting with the lens profile"`
+	AutoLateralCA     int `json:"auto_lateral_ca" schema:"min=0,max=1,optional" desc:"1 removes lateral chromatic aberration"`
 
 	// HSL - Hue
-	HueAdjustmentRed     int `json:"hue_red"`
-	HueAdjustmentOrange  int `json:"hue_orange"`
-	HueAdjustmentYellow  int `json:"hue_yellow"`
-	HueAdjustmentGreen   int `json:"hue_green"`
-	HueAdjustmentAqua    int `json:"hue_aqua"`
-	HueAdjustmentBlue    int `json:"hue_blue"`
-	HueAdjustmentPurple  int `json:"hue_purple"`
-	HueAdjustmentMagenta int `json:"hue_magenta"`
+	HueAdjustmentRed     int `json:"hue_red" schema:"min=-100,max=100"`
+	HueAdjustmentOrange  int `json:"hue_orange" schema:"min=-100,max=100"`
+	HueAdjustmentYellow  int `json:"hue_yellow" schema:"min=-100,max=100"`
+	HueAdjustmentGreen   int `json:"hue_green" schema:"min=-100,max=100"`
+	HueAdjustmentAqua    int `json:"hue_aqua" schema:"min=-100,max=100"`
+	HueAdjustmentBlue    int `json:"hue_blue" schema:"min=-100,max=100"`
+	HueAdjustmentPurple  int `json:"hue_purple" schema:"min=-100,max=100"`
+	HueAdjustmentMagenta int `json:"hue_magenta" schema:"min=-100,max=100"`
 
 	// HSL - Saturation
-	SaturationAdjustmentRed     int `json:"saturation_red"`
-	SaturationAdjustmentOrange  int `json:"saturation_orange"`
-	SaturationAdjustmentYellow  int `json:"saturation_yellow"`
-	SaturationAdjustmentGreen   int `json:"saturation_green"`
-	SaturationAdjustmentAqua    int `json:"saturation_aqua"`
-	SaturationAdjustmentBlue    int `json:"saturation_blue"`
-	SaturationAdjustmentPurple  int `json:"saturation_purple"`
-	SaturationAdjustmentMagenta int `json:"saturation_magenta"`
+	SaturationAdjustmentRed     int `json:"saturation_red" schema:"min=-100,max=100"`
+	SaturationAdjustmentOrange  int `json:"saturation_orange" schema:"min=-100,max=100"`
+	SaturationAdjustmentYellow  int `json:"saturation_yellow" schema:"min=-100,max=100"`
+	SaturationAdjustmentGreen   int `json:"saturation_green" schema:"min=-100,max=100"`
+	SaturationAdjustmentAqua    int `json:"saturation_aqua" schema:"min=-100,max=100"`
+	SaturationAdjustmentBlue    int `json:"saturation_blue" schema:"min=-100,max=100"`
+	SaturationAdjustmentPurple  int `json:"saturation_purple" schema:"min=-100,max=100"`
+	SaturationAdjustmentMagenta int `json:"saturation_magenta" schema:"min=-100,max=100"`
 
 	// HSL - Luminance
-	LuminanceAdjustmentRed     int `json:"luminance_red"`
-	LuminanceAdjustmentOrange  int `json:"luminance_orange"`
-	LuminanceAdjustmentYellow  int `json:"luminance_yellow"`
-	LuminanceAdjustmentGreen   int `json:"luminance_green"`
-	LuminanceAdjustmentAqua    int `json:"luminance_aqua"`
-	LuminanceAdjustmentBlue    int `json:"luminance_blue"`
-	LuminanceAdjustmentPurple  int `json:"luminance_purple"`
-	LuminanceAdjustmentMagenta int `json:"luminance_magenta"`
+	LuminanceAdjustmentRed     int `json:"luminance_red" schema:"min=-100,max=100"`
+	LuminanceAdjustmentOrange  int `json:"luminance_orange" schema:"min=-100,max=100"`
+	LuminanceAdjustmentYellow  int `json:"luminance_yellow" schema:"min=-100,max=100"`
+	LuminanceAdjustmentGreen   int `json:"luminance_green" schema:"min=-100,max=100"`
+	LuminanceAdjustmentAqua    int `json:"luminance_aqua" schema:"min=-100,max=100"`
+	LuminanceAdjustmentBlue    int `json:"luminance_blue" schema:"min=-100,max=100"`
+	LuminanceAdjustmentPurple  int `json:"luminance_purple" schema:"min=-100,max=100"`
+	LuminanceAdjustmentMagenta int `json:"luminance_magenta" schema:"min=-100,max=100"`
 
 	// Split Toning (Simple)
-	SplitToningShadowHue           int `json:"split_shadow_hue"`
-	SplitToningShadowSaturation    int `json:"split_shadow_saturation"`
-	SplitToningHighlightHue        int `json:"split_highlight_hue"`
-	SplitToningHighlightSaturation int `json:"split_highlight_saturation"`
-	SplitToningBalance             int `json:"split_balance"`
+	SplitToningShadowHue           int `json:"split_shadow_hue" schema:"min=0,max=360"`
+	SplitToningShadowSaturation    int `json:"split_shadow_saturation" schema:"min=0,max=100"`
+	SplitToningHighlightHue        int `json:"split_highlight_hue" schema:"min=0,max=360"`
+	SplitToningHighlightSaturation int `json:"split_highlight_saturation" schema:"min=0,max=100"`
+	SplitToningBalance             int `json:"split_balance" schema:"min=-100,max=100"`
+
+	// Black & White. The mixer sets how bright each color renders in gray.
+	ConvertToGrayscale bool `json:"convert_to_grayscale" schema:"optional" desc:"true for a black and white conversion"`
+	GrayMixerRed       int  `json:"gray_mixer_red" schema:"min=-100,max=100,optional"`
+	GrayMixerOrange    int  `json:"gray_mixer_orange" schema:"min=-100,max=100,optional"`
+	GrayMixerYellow    int  `json:"gray_mixer_yellow" schema:"min=-100,max=100,optional"`
+	GrayMixerGreen     int  `json:"gray_mixer_green" schema:"min=-100,max=100,optional"`
+	GrayMixerAqua      int  `json:"gray_mixer_aqua" schema:"min=-100,max=100,optional"`
+	GrayMixerBlue      int  `json:"gray_mixer_blue" schema:"min=-100,max=100,optional"`
+	GrayMixerPurple    int  `json:"gray_mixer_purple" schema:"min=-100,max=100,optional"`
+	GrayMixerMagenta   int  `json:"gray_mixer_magenta" schema:"min=-100,max=100,optional"`
+
+	// Color Grading (three-way wheels). Lightroom keeps the hue and saturation
+	// of the shadow and highlight wheels in the split toning values above, and
+	// their balance in split_balance; these fields add the rest.
+	ColorGradeShadowLum    int `json:"color_grade_shadow_lum" schema:"min=-100,max=100,optional"`
+	ColorGradeMidtoneHue   int `json:"color_grade_midtone_hue" schema:"min=0,max=359,optional"`
+	ColorGradeMidtoneSat   int `json:"color_grade_midtone_sat" schema:"min=0,max=100,optional"`
+	ColorGradeMidtoneLum   int `json:"color_grade_midtone_lum" schema:"min=-100,max=100,optional"`
+	ColorGradeHighlightLum int `json:"color_grade_highlight_lum" schema:"min=-100,max=100,optional"`
+	ColorGradeGlobalHue    int `json:"color_grade_global_hue" schema:"min=0,max=359,optional"`
+	ColorGradeGlobalSat    int `json:"color_grade_global_sat" schema:"min=0,max=100,optional"`
+	ColorGradeGlobalLum    int `json:"color_grade_global_lum" schema:"min=-100,max=100,optional"`
+	ColorGradeBlending     int `json:"color_grade_blending" schema:"min=0,max=100,optional" desc:"overlap of the wheels, 0 keeps the default of 50"`
+
+	// Parametric Tone Curve
+	ParametricShadows    int `json:"parametric_shadows" schema:"min=-100,max=100,optional"`
+	ParametricDarks      int `json:"parametric_darks" schema:"min=-100,max=100,optional"`
+	ParametricLights     int `json:"parametric_lights" schema:"min=-100,max=100,optional"`
+	ParametricHighlights int `json:"parametric_highlights" schema:"min=-100,max=100,optional"`
+
+	// Point Curves: master and per channel, as [input, output] pairs from 0 to 255
+	ToneCurvePV2012      [][]float64 `json:"tone_curve" schema:"min=0,max=255,optional" desc:"control points as [input, output] pairs, values 0 to 255"`
+	ToneCurvePV2012Red   [][]float64 `json:"tone_curve_red" schema:"min=0,max=255,optional" desc:"control points as [input, output] pairs, values 0 to 255"`
+	ToneCurvePV2012Green [][]float64 `json:"tone_curve_green" schema:"min=0,max=255,optional" desc:"control points as [input, output] pairs, values 0 to 255"`
+	ToneCurvePV2012Blue  [][]float64 `json:"tone_curve_blue" schema:"min=0,max=255,optional" desc:"control points as [input, output] pairs, values 0 to 255"`
+
+	// Local Adjustments (masks)
+	LocalAdjustments []LocalAdjustment `json:"local_adjustments,omitempty" schema:"optional" desc:"at most 4 masked corrections"`
+
+	// Crop and straighten, requested with AnalysisOptions.Crop
+	Crop *CropSuggestion `json:"crop,omitempty" schema:"optional" desc:"suggested crop and horizon correction"`
+
+	// Scene description, requested with AnalysisOptions.Describe
+	Scene *SceneInfo `json:"scene,omitempty" schema:"optional" desc:"what the image shows, for cataloging"`
+}
+
+// CropSuggestion is a suggested crop and straightening. The rectangle is
+// given in fractions of the upright image's width and height, measured from
+// the top left corner, before the rotation by Angle.
+type CropSuggestion struct {
+	Top    float64 `json:"top" schema:"min=0,max=1"`
+	Left   float64 `json:"left" schema:"min=0,max=1"`
+	Bottom float64 `json:"bottom" schema:"min=0,max=1"`
+	Right  float64 `json:"right" schema:"min=0,max=1"`
+	Angle  float64 `json:"angle" schema:"min=-45,max=45" desc:"degrees to rotate clockwise to level the horizon, 0 if level"`
+	Aspect string  `json:"aspect" schema:"optional" desc:"target aspect ratio, e.g. 4:5, 1:1, 16:9, or original"`
+
+	// Width and Height are the pixel size of the upright image. They are
+	// set by the processor, not the model: RawTherapee crops in pixels.
+	Width  int `json:"-"`
+	Height int `json:"-"`
+}
+
+// Local adjustment mask types.
+const (
+	MaskLinear  = "linear"  // graduated filter
+	MaskRadial  = "radial"  // elliptical gradient
+	MaskSky     = "sky"     // sky detected by the editor
+	MaskSubject = "subject" // main subject detected by the editor
+)
+
+// LocalAdjustment is a correction applied through a mask to part of the
+// image. Positions are fractions of the image width and height, measured from
+// the top left corner. Sky and subject masks are computed by the editor and
+// ignore the positions.
+type LocalAdjustment struct {
+	Type string `json:"type" desc:"linear, radial, sky or subject"`
+	Name string `json:"name" schema:"optional" desc:"what it corrects, e.g. darken sky"`
+
+	// Linear: the effect is full at (X1, Y1) and fades out towards (X2, Y2).
+	// Radial: (X1, Y1) and (X2, Y2) are opposite corners of the ellipse's bounding box.
+	X1      float64 `json:"x1" schema:"min=0,max=1,optional"`
+	Y1      float64 `json:"y1" schema:"min=0,max=1,optional"`
+	X2      float64 `json:"x2" schema:"min=0,max=1,optional"`
+	Y2      float64 `json:"y2" schema:"min=0,max=1,optional"`
+	Feather int     `json:"feather" schema:"min=0,max=100,optional" desc:"edge softness of radial masks"`
+
+	Exposure    float64 `json:"exposure" schema:"min=-4,max=4" desc:"stops"`
+	Contrast    int     `json:"contrast" schema:"min=-100,max=100"`
+	Temperature int     `json:"temperature" schema:"min=-100,max=100" desc:"negative is cooler, positive warmer"`
+}
+
+// SceneInfo describes the content of an image. It is written to the sidecars
+// as searchable metadata (dc:title, dc:description, dc:subject in XMP).
+type SceneInfo struct {
+	Title    string   `json:"title" desc:"suggested title, a few words"`
+	Caption  string   `json:"caption" desc:"one short sentence describing the image"`
+	Type     string   `json:"scene_type" desc:"e.g. portrait, landscape, street, architecture, event, wildlife"`
+	Keywords []string `json:"keywords" desc:"5 to 15 lowercase subject keywords"`
+}
+
+// Subjects returns the keywords followed by the scene type, trimmed and
+// without duplicates, for use as catalog keywords.
+func (s *SceneInfo) Subjects() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, k := range append(append([]string{}, s.Keywords...), s.Type) {
+		k = strings.TrimSpace(k)
+		if k != "" && !seen[strings.ToLower(k)] {
+			seen[strings.ToLower(k)] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// StyleChoice is the result of automatic style selection: the kind of scene
+// the photo shows and the catalog style picked for it.
+type StyleChoice struct {
+	Scene string `json:"scene" desc:"e.g. portrait, landscape, food, night city, snow, street, product"`
+	Style string `json:"style" desc:"name of the best matching style, exactly as listed"`
 }
 
 // PP3Params defines RawTherapee native parameters for direct PP3 generation.
 // These are designed to work with RT's processing pipeline without conversion loss.
 type PP3Params struct {
 	// Exposure
-	Compensation float64 `json:"compensation"` // -5.0 to +12.0
-	Contrast     int     `json:"contrast"`     // -100 to 100
-	Saturation   int     `json:"saturation"`   // -100 to 100
-	Black        int     `json:"black"`        // 0 to 32768
+	Compensation float64 `json:"compensation" schema:"min=-5,max=12"`  // -5.0 to +12.0
+	Contrast     int     `json:"contrast" schema:"min=-100,max=100"`   // -100 to 100
+	Saturation   int     `json:"saturation" schema:"min=-100,max=100"` // -100 to 100
+	Black        int     `json:"black" schema:"min=0,max=32768"`       // 0 to 32768
 
 	// Highlight & Shadow Recovery
-	HighlightCompr    int `json:"highlight_compr"`    // 0 to 500
-	ShadowRecovery    int `json:"shadow_recovery"`    // 0 to 100
-	HighlightRecovery int `json:"highlight_recovery"` // 0 to 100
+	HighlightCompr    int `json:"highlight_compr" schema:"min=0,max=500"`    // 0 to 500
+	ShadowRecovery    int `json:"shadow_recovery" schema:"min=0,max=100"`    // 0 to 100
+	HighlightRecovery int `json:"highlight_recovery" schema:"min=0,max=100"` // 0 to 100
 
 	// White Balance
-	Temperature int     `json:"temperature"` // 1500 to 60000
-	Tint        float64 `json:"tint"`        // 0.02 to 10.0 (1.0 = neutral)
+	Temperature int     `json:"temperature" schema:"min=1500,max=60000"`             // 1500 to 60000, 0 keeps the camera's
+	Tint        float64 `json:"tint" schema:"min=0.02,max=10" desc:"1.0 is neutral"` // 0.02 to 10.0 (1.0 = neutral)
 
 	// Lab Adjustments (key for color pop)
-	LabBrightness   int `json:"lab_brightness"`   // -100 to 100
-	LabContrast     int `json:"lab_contrast"`     // -100 to 100
-	LabChromaticity int `json:"lab_chromaticity"` // -100 to 100
+	LabBrightness   int `json:"lab_brightness" schema:"min=-100,max=100"`   // -100 to 100
+	LabContrast     int `json:"lab_contrast" schema:"min=-100,max=100"`     // -100 to 100
+	LabChromaticity int `json:"lab_chromaticity" schema:"min=-100,max=100"` // -100 to 100
 
 	// Local Contrast / Clarity (SharpenMicro)
-	SharpenMicroStrength   int `json:"sharpenmicro_strength"`   // 0 to 100
-	SharpenMicroContrast   int `json:"sharpenmicro_contrast"`   // 0 to 100
-	SharpenMicroUniformity int `json:"sharpenmicro_uniformity"` // 0 to 100
+	SharpenMicroStrength   int `json:"sharpenmicro_strength" schema:"min=0,max=100"`   // 0 to 100
+	SharpenMicroContrast   int `json:"sharpenmicro_contrast" schema:"min=0,max=100"`   // 0 to 100
+	SharpenMicroUniformity int `json:"sharpenmicro_uniformity" schema:"min=0,max=100"` // 0 to 100
 
 	// Dehaze
-	DehazeStrength int `json:"dehaze_strength"` // -100 to 100
+	DehazeStrength int `json:"dehaze_strength" schema:"min=-100,max=100"` // -100 to 100
 
 	// Vibrance
-	VibPastels   int `json:"vib_pastels"`   // -100 to 100
-	VibSaturated int `json:"vib_saturated"` // -100 to 100
+	VibPastels   int `json:"vib_pastels" schema:"min=-100,max=100"`   // -100 to 100
+	VibSaturated int `json:"vib_saturated" schema:"min=-100,max=100"` // -100 to 100
 
 	// Sharpening (output sharpening)
-	SharpenEnabled  bool    `json:"sharpen_enabled"`  // enable sharpening
-	SharpenAmount   int     `json:"sharpen_amount"`   // 0 to 1000
-	SharpenRadius   float64 `json:"sharpen_radius"`   // 0.3 to 3.0
-	SharpenContrast int     `json:"sharpen_contrast"` // 0 to 100
+	SharpenEnabled  bool    `json:"sharpen_enabled" schema:"optional"`                // enable sharpening
+	SharpenAmount   int     `json:"sharpen_amount" schema:"min=0,max=1000,optional"`  // 0 to 1000
+	SharpenRadius   float64 `json:"sharpen_radius" schema:"min=0,max=3,optional"`     // 0.3 to 3.0, 0 when disabled
+	SharpenContrast int     `json:"sharpen_contrast" schema:"min=0,max=100,optional"` // 0 to 100
 
 	// Edge Sharpening
-	EdgeSharpenEnabled bool `json:"edge_sharpen_enabled"` // enable edge sharpening
-	EdgeSharpenAmount  int  `json:"edge_sharpen_amount"`  // 0 to 100
-	EdgeSharpenPasses  int  `json:"edge_sharpen_passes"`  // 1 to 4
+	EdgeSharpenEnabled bool `json:"edge_sharpen_enabled" schema:"optional"`              // enable edge sharpening
+	EdgeSharpenAmount  int  `json:"edge_sharpen_amount" schema:"min=0,max=100,optional"` // 0 to 100
+	EdgeSharpenPasses  int  `json:"edge_sharpen_passes" schema:"min=0,max=4,optional"`   // 1 to 4, 0 when disabled
 
 	// Capture Sharpening (demosaic level - critical for perceived sharpness)
-	CaptureSharpEnabled bool    `json:"capture_sharp_enabled"` // enable capture sharpening
-	CaptureSharpAmount  int     `json:"capture_sharp_amount"`  // 0 to 200
-	CaptureSharpRadius  float64 `json:"capture_sharp_radius"`  // 0.5 to 2.0
+	CaptureSharpEnabled bool    `json:"capture_sharp_enabled" schema:"optional"`              // enable capture sharpening
+	CaptureSharpAmount  int     `json:"capture_sharp_amount" schema:"min=0,max=200,optional"` // 0 to 200
+	CaptureSharpRadius  float64 `json:"capture_sharp_radius" schema:"min=0,max=2,optional"`   // 0.5 to 2.0, 0 when disabled
 
 	// Noise Reduction
-	NRLuminance   int `json:"nr_luminance"`   // 0 to 100
-	NRChrominance int `json:"nr_chrominance"` // 0 to 100
+	NRLuminance   int `json:"nr_luminance" schema:"min=0,max=100"`   // 0 to 100
+	NRChrominance int `json:"nr_chrominance" schema:"min=0,max=100"` // 0 to 100
 
 	// Tone Curve (S-curve control points)
 	// Format: array of [x, y] pairs, x and y are 0.0 to 1.0
-	ToneCurve [][]float64 `json:"tone_curve"`
+	ToneCurve [][]float64 `json:"tone_curve" schema:"optional" desc:"control points as [x, y] pairs, values 0.0 to 1.0"`
 
 	// L Curve (Luminance curve in Lab space)
-	LCurve [][]float64 `json:"l_curve"`
+	LCurve [][]float64 `json:"l_curve" schema:"optional" desc:"control points as [x, y] pairs, values 0.0 to 1.0"`
 
 	// RGB Curves for color grading
-	RCurve [][]float64 `json:"r_curve"`
-	GCurve [][]float64 `json:"g_curve"`
-	BCurve [][]float64 `json:"b_curve"`
+	RCurve [][]float64 `json:"r_curve" schema:"optional" desc:"control points as [x, y] pairs, values 0.0 to 1.0"`
+	GCurve [][]float64 `json:"g_curve" schema:"optional" desc:"control points as [x, y] pairs, values 0.0 to 1.0"`
+	BCurve [][]float64 `json:"b_curve" schema:"optional" desc:"control points as [x, y] pairs, values 0.0 to 1.0"`
 
 	// Color Toning (Split Toning)
-	ColorToningShadowR    int `json:"ct_shadow_r"`    // -100 to 100
-	ColorToningShadowG    int `json:"ct_shadow_g"`    // -100 to 100
-	ColorToningShadowB    int `json:"ct_shadow_b"`    // -100 to 100
-	ColorToningHighlightR int `json:"ct_highlight_r"` // -100 to 100
-	ColorToningHighlightG int `json:"ct_highlight_g"` // -100 to 100
-	ColorToningHighlightB int `json:"ct_highlight_b"` // -100 to 100
-	ColorToningBalance    int `json:"ct_balance"`     // 0 to 100
+	ColorToningShadowR    int `json:"ct_shadow_r" schema:"min=-100,max=100"`    // -100 to 100
+	ColorToningShadowG    int `json:"ct_shadow_g" schema:"min=-100,max=100"`    // -100 to 100
+	ColorToningShadowB    int `json:"ct_shadow_b" schema:"min=-100,max=100"`    // -100 to 100
+	ColorToningHighlightR int `json:"ct_highlight_r" schema:"min=-100,max=100"` // -100 to 100
+	ColorToningHighlightG int `json:"ct_highlight_g" schema:"min=-100,max=100"` // -100 to 100
+	ColorToningHighlightB int `json:"ct_highlight_b" schema:"min=-100,max=100"` // -100 to 100
+	ColorToningBalance    int `json:"ct_balance" schema:"min=0,max=100"`        // 0 to 100
+
+	// Black & White (channel mixer): the share of each color in the gray value
+	BWEnabled      bool `json:"bw_enabled" schema:"optional" desc:"convert to black and white"`
+	BWMixerRed     int  `json:"bw_mixer_red" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerOrange  int  `json:"bw_mixer_orange" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerYellow  int  `json:"bw_mixer_yellow" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerGreen   int  `json:"bw_mixer_green" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerCyan    int  `json:"bw_mixer_cyan" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerBlue    int  `json:"bw_mixer_blue" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerMagenta int  `json:"bw_mixer_magenta" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
+	BWMixerPurple  int  `json:"bw_mixer_purple" schema:"min=-100,max=200,optional" desc:"0 keeps the default of 33"`
 
 	// Vignette
-	VignetteAmount int `json:"vignette_amount"` // -100 to 100
+	VignetteAmount    int `json:"vignette_amount" schema:"min=-100,max=100"`                                           // -100 to 100
+	VignetteFeather   int `json:"vignette_feather" schema:"min=0,max=100,optional" desc:"0 keeps the default of 50"`   // 0 to 100
+	VignetteRoundness int `json:"vignette_roundness" schema:"min=0,max=100,optional" desc:"0 keeps the default of 50"` // 0 to 100
+
+	// Lens Correction
+	LensProfile bool `json:"lens_profile" schema:"optional" desc:"correct distortion and vignetting with the lens profile"`
+
+	// Local Adjustments (Locallab spots)
+	LocalAdjustments []LocalAdjustment `json:"local_adjustments,omitempty" schema:"optional" desc:"at most 4 masked corrections"`
+
+	// Crop and straighten, requested with AnalysisOptions.Crop
+	Crop *CropSuggestion `json:"crop,omitempty" schema:"optional" desc:"suggested crop and horizon correction"`
+
+	// Scene description, requested with AnalysisOptions.Describe
+	Scene *SceneInfo `json:"scene,omitempty" schema:"optional" desc:"what the image shows, for cataloging"`
 }
 
 // ProcessingResult holds the outcome of processing a single file.
@@ -166,5 +351,40 @@ type ProcessingResult struct {
 	Params     GradingParams
 	PP3Params  *PP3Params
 	Metadata   Metadata
+	Style      string        // style the photo was graded with
+	AutoStyle  *StyleChoice  // how the style was picked, set for --style auto
+	Spread     []ParamSpread // disagreement between consensus samples, if several were taken
+	Sanitized  []string      // LR values that were out of range and replaced before writing
+	Usage      Usage         // tokens spent on AI calls for this file; cache hits are free
 	Error      error
 }
+
+// Usage counts the tokens spent on AI calls.
+type Usage struct {
+	Model        string // model that served the calls, used to look up prices
+	Calls        int
+	PromptTokens int // text input
+	ImageTokens  int // image input; estimated from the image size when the API reports only a total
+	OutputTokens int
+}
+
+// Add accumulates other into u.
+func (u *Usage) Add(other Usage) {
+	if other.Model != "" {
+		u.Model = other.Model
+	}
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.ImageTokens += other.ImageTokens
+	u.OutputTokens += other.OutputTokens
+}
+
+// ParamSpread reports how far consensus samples disagreed on one parameter.
+type ParamSpread struct {
+	Format   string // "xmp" or "pp3"
+	Field    string // JSON field name
+	Min      float64
+	Max      float64
+	Median   float64 // the value that was used
+	Unstable bool    // the spread exceeds 10% of the field's valid range
+}
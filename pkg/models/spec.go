@@ -0,0 +1,65 @@
+package models
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldSpec describes one JSON field of a parameter struct as declared by its
+// struct tags:
+//
+//	json:"name"                      the field name sent to and read from the model
+//	schema:"min=-100,max=100"        the valid range (numbers, or the leaves of number arrays)
+//	schema:"optional"                the model may omit the field
+//	desc:"..."                       a short hint included in the schema
+type FieldSpec struct {
+	Name     string
+	Index    []int
+	Type     reflect.Type
+	Min      float64
+	Max      float64
+	HasRange bool
+	Optional bool
+	Desc     string
+}
+
+// Fields returns the specs of all JSON-tagged fields of the struct type t, in declaration order.
+func Fields(t reflect.Type) []FieldSpec {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var specs []FieldSpec
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		spec := FieldSpec{
+			Name:  name,
+			Index: f.Index,
+			Type:  f.Type,
+			Desc:  f.Tag.Get("desc"),
+		}
+		var hasMin, hasMax bool
+		for _, opt := range strings.Split(f.Tag.Get("schema"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "min":
+				spec.Min, _ = strconv.ParseFloat(value, 64)
+				hasMin = true
+			case "max":
+				spec.Max, _ = strconv.ParseFloat(value, 64)
+				hasMax = true
+			case "optional":
+				spec.Optional = true
+			}
+		}
+		spec.HasRange = hasMin && hasMax
+		specs = append(specs, spec)
+	}
+	return specs
+}